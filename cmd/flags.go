@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Flags are the global flags of the exporter, they are shared by all commands.
+var Flags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "config.file",
+		Usage:   "Path to the YAML configuration file, explicitly set flags take precedence over its values.",
+		EnvVars: []string{"CONFIG_FILE"},
+	},
+	&cli.StringFlag{
+		Name:    "web.listen-address",
+		Usage:   "Address on which to expose metrics and web interface.",
+		EnvVars: []string{"WEB_LISTEN_ADDRESS"},
+		Value:   ":9127",
+	},
+	&cli.StringFlag{
+		Name:    "web.telemetry-path",
+		Usage:   "Path under which to expose metrics.",
+		EnvVars: []string{"WEB_TELEMETRY_PATH"},
+		Value:   "/metrics",
+	},
+	&cli.BoolFlag{
+		Name:    "web.enable-lifecycle",
+		Usage:   "Enable reloading of the configuration using the /-/reload endpoint.",
+		EnvVars: []string{"WEB_ENABLE_LIFECYCLE"},
+	},
+	&cli.BoolFlag{
+		Name:    "web.enable-snapshot",
+		Usage:   "Enable the /api/v1/snapshot endpoint serving the raw rows of the admin console.",
+		EnvVars: []string{"WEB_ENABLE_SNAPSHOT"},
+	},
+	&cli.StringFlag{
+		Name:    "store",
+		Usage:   "Store used to read pgbouncer stats, sql queries the admin console using lib/pq, pgx queries it using pgx and a persistent connection, log parses the stats lines of the pgbouncer log.",
+		EnvVars: []string{"STORE"},
+		Value:   "sql",
+	},
+	&cli.BoolFlag{
+		Name:    "store-strict-columns",
+		Usage:   "Fail scrapes when the admin console returns unknown columns instead of ignoring them.",
+		EnvVars: []string{"STORE_STRICT_COLUMNS"},
+	},
+	&cli.StringFlag{
+		Name:    "store-log-file",
+		Usage:   "Pgbouncer log file followed by the log store, - reads the log from stdin.",
+		EnvVars: []string{"STORE_LOG_FILE"},
+	},
+	&cli.DurationFlag{
+		Name:    "store-log-stats-period",
+		Usage:   "The stats_period of pgbouncer, used by the log store to compute totals from the logged averages.",
+		EnvVars: []string{"STORE_LOG_STATS_PERIOD"},
+		Value:   time.Minute,
+	},
+	&cli.StringFlag{
+		Name:    "database-url",
+		Usage:   "Database connection url.",
+		EnvVars: []string{"DATABASE_URL"},
+	},
+	&cli.StringFlag{
+		Name:    "database-url-file",
+		Usage:   "File containing the database connection url, used instead of database-url.",
+		EnvVars: []string{"DATABASE_URL_FILE"},
+	},
+	&cli.StringFlag{
+		Name:    "database-password-file",
+		Usage:   "File containing the database password, overrides the password in the connection url.",
+		EnvVars: []string{"DATABASE_PASSWORD_FILE"},
+	},
+	&cli.DurationFlag{
+		Name:    "database-credentials-reload-interval",
+		Usage:   "Interval in which the database url and password files are checked for changes, 0 disables reloading.",
+		EnvVars: []string{"DATABASE_CREDENTIALS_RELOAD_INTERVAL"},
+		Value:   time.Second * 30,
+	},
+	&cli.StringFlag{
+		Name:    "database-socket-dir",
+		Usage:   "Directory containing the pgbouncer unix socket, used instead of database-url.",
+		EnvVars: []string{"DATABASE_SOCKET_DIR"},
+	},
+	&cli.IntFlag{
+		Name:    "database-port",
+		Usage:   "Port of the pgbouncer unix socket.",
+		EnvVars: []string{"DATABASE_PORT"},
+		Value:   6432,
+	},
+	&cli.StringFlag{
+		Name:    "database-user",
+		Usage:   "User used to connect over the unix socket, defaults to the operating system user.",
+		EnvVars: []string{"DATABASE_USER"},
+	},
+	&cli.StringFlag{
+		Name:    "database-name",
+		Usage:   "Name of the pgbouncer admin database used to connect over the unix socket.",
+		EnvVars: []string{"DATABASE_NAME"},
+		Value:   "pgbouncer",
+	},
+	&cli.BoolFlag{
+		Name:    "export-stats",
+		Usage:   "Export stats.",
+		EnvVars: []string{"EXPORT_STATS"},
+		Value:   true,
+	},
+	&cli.BoolFlag{
+		Name:    "export-pools",
+		Usage:   "Export pools.",
+		EnvVars: []string{"EXPORT_POOLS"},
+		Value:   true,
+	},
+	&cli.BoolFlag{
+		Name:    "export-databases",
+		Usage:   "Export databases.",
+		EnvVars: []string{"EXPORT_DATABASES"},
+		Value:   true,
+	},
+	&cli.BoolFlag{
+		Name:    "export-lists",
+		Usage:   "Export lists.",
+		EnvVars: []string{"EXPORT_LISTS"},
+		Value:   true,
+	},
+	&cli.BoolFlag{
+		Name:    "export-totals",
+		Usage:   "Export totals.",
+		EnvVars: []string{"EXPORT_TOTALS"},
+		Value:   true,
+	},
+	&cli.StringFlag{
+		Name:    "mappings.file",
+		Usage:   "Path to a YAML file with additional mappings of admin console columns to metrics.",
+		EnvVars: []string{"MAPPINGS_FILE"},
+	},
+	&cli.StringFlag{
+		Name:    "queries.file",
+		Usage:   "Path to a YAML file with custom admin console queries and the mappings of their columns to metrics.",
+		EnvVars: []string{"QUERIES_FILE"},
+	},
+	&cli.DurationFlag{
+		Name:    "store-timeout",
+		Usage:   "Per method store timeout.",
+		EnvVars: []string{"STORE_TIMEOUT"},
+		Value:   time.Second * 2,
+	},
+	&cli.StringFlag{
+		Name:    "default-labels",
+		Usage:   "Default prometheus labels applied to all metrics. Format: label1=value1 label2=value2",
+		EnvVars: []string{"DEFAULT_LABELS"},
+	},
+	&cli.StringFlag{
+		Name:    "filter.metrics.include",
+		Usage:   "Regular expression of metric names to include, all are included when empty.",
+		EnvVars: []string{"FILTER_METRICS_INCLUDE"},
+	},
+	&cli.StringFlag{
+		Name:    "filter.metrics.exclude",
+		Usage:   "Regular expression of metric names to exclude.",
+		EnvVars: []string{"FILTER_METRICS_EXCLUDE"},
+	},
+	&cli.StringFlag{
+		Name:    "filter.databases.include",
+		Usage:   "Regular expression of database label values to include, all are included when empty.",
+		EnvVars: []string{"FILTER_DATABASES_INCLUDE"},
+	},
+	&cli.StringFlag{
+		Name:    "filter.databases.exclude",
+		Usage:   "Regular expression of database label values to exclude.",
+		EnvVars: []string{"FILTER_DATABASES_EXCLUDE"},
+	},
+	&cli.StringFlag{
+		Name:    "filter.users.include",
+		Usage:   "Regular expression of user label values to include, all are included when empty.",
+		EnvVars: []string{"FILTER_USERS_INCLUDE"},
+	},
+	&cli.StringFlag{
+		Name:    "filter.users.exclude",
+		Usage:   "Regular expression of user label values to exclude.",
+		EnvVars: []string{"FILTER_USERS_EXCLUDE"},
+	},
+}
@@ -1,12 +1,10 @@
 package cmd
 
 import (
-	"context"
-	"database/sql"
 	"fmt"
+	"os"
 
 	"github.com/jbub/pgbouncer_exporter/internal/config"
-	"github.com/jbub/pgbouncer_exporter/internal/sqlstore"
 
 	"github.com/urfave/cli/v2"
 )
@@ -19,21 +17,41 @@ var Health = &cli.Command{
 }
 
 func checkHealth(ctx *cli.Context) error {
-	cfg := config.LoadFromCLI(ctx)
-
-	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	cfg, err := config.LoadFromCLI(ctx)
 	if err != nil {
-		return fmt.Errorf("could not open db: %v", err)
+		return err
 	}
-	defer db.Close() //nolint:errcheck
-
-	store := sqlstore.New(db)
 
-	checkCtx, cancel := context.WithTimeout(context.Background(), cfg.StoreTimeout)
-	defer cancel()
+	if cfg.Store == config.StoreLog {
+		return checkLogFile(cfg.LogFile)
+	}
 
-	if err := store.Check(checkCtx); err != nil {
-		return fmt.Errorf("store health check failed: %v", err)
+	for _, target := range cfg.Targets {
+		if cfg.Store == config.StorePgx {
+			store, err := openPgxStore(cfg, target)
+			if err != nil {
+				return err
+			}
+			_ = store.Close()
+			continue
+		}
+
+		store, _, err := openStore(cfg, target)
+		if err != nil {
+			return err
+		}
+		_ = store.Close()
 	}
 	return nil
 }
+
+func checkLogFile(path string) error {
+	if path == "-" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open log file: %v", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/jbub/pgbouncer_exporter/internal/collector"
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/otlp"
+
+	"github.com/prometheus/common/version"
+	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel"
+)
+
+// OTLP is a cli command used for pushing metrics to an OpenTelemetry collector.
+var OTLP = &cli.Command{
+	Name:   "otlp",
+	Usage:  "Periodically pushes metrics using OTLP, configured by the OTEL_* environment variables.",
+	Action: runOTLP,
+}
+
+func runOTLP(ctx *cli.Context) error {
+	cfg, err := config.LoadFromCLI(ctx)
+	if err != nil {
+		return err
+	}
+
+	exps, closeStores, err := openExporters(ctx.Context, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStores()
+
+	reloader := collector.NewReloader(cfg, func() (config.Config, error) {
+		return config.LoadFromCLI(ctx)
+	}, exps...)
+	stopReload := reloadOnSignal(ctx.Context, reloader)
+	defer stopReload()
+
+	reg := collector.NewRegistry(exps...)
+	reg.MustRegister(reloader)
+
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Printf("could not push metrics: %v", err)
+	}))
+
+	pusher, err := otlp.New(ctx.Context, reg)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Starting ", collector.Name, version.Info())
+	log.Println("Pushing metrics using OTLP", otlp.Protocol())
+	log.Println("Build context", version.BuildContext())
+
+	if err := pusher.Run(ctx.Context); err != nil {
+		return fmt.Errorf("could not push metrics: %v", err)
+	}
+	return nil
+}
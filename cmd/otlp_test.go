@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/otlptest"
+	"github.com/jbub/pgbouncer_exporter/internal/pgbouncertest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTLPEndToEnd(t *testing.T) {
+	fixture, err := pgbouncertest.LoadFixture("1.24")
+	require.NoError(t, err)
+
+	srv := pgbouncertest.NewServer(fixture)
+	defer srv.Close() //nolint:errcheck
+
+	// the receiver is closed after the command is stopped, because the command pushes again on shutdown
+	receiver := otlptest.NewReceiver()
+	t.Cleanup(func() { _ = receiver.Close() })
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", receiver.HTTPEndpoint())
+	t.Setenv("OTEL_METRIC_EXPORT_INTERVAL", "20")
+
+	runApp(t, OTLP, "--database-url", srv.URL(), "--default-labels", "instance=pg1", "otlp")
+
+	require.Eventually(t, func() bool {
+		_, ok := receiver.Metric("pgbouncer_exporter_totals_xacts_total")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	met, ok := receiver.Metric("pgbouncer_exporter_pools_active_clients")
+	require.True(t, ok)
+
+	points := make(map[string]float64)
+	for _, point := range met.GetGauge().GetDataPoints() {
+		attrs := otlptest.Attributes(point.GetAttributes())
+		require.Equal(t, "pg1", attrs["instance"])
+		points[attrs["database"]+"/"+attrs["user"]] = point.GetAsDouble()
+	}
+	require.Equal(t, 12.0, points["app/app"])
+
+	met, ok = receiver.Metric("pgbouncer_exporter_totals_xacts_total")
+	require.True(t, ok)
+	require.Equal(t, fixtureTotalXacts, met.GetSum().GetDataPoints()[0].GetAsDouble())
+}
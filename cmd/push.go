@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/collector"
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/push"
+
+	"github.com/prometheus/common/version"
+	"github.com/urfave/cli/v2"
+)
+
+// Push is a cli command used for pushing metrics to a Pushgateway or a remote write endpoint.
+var Push = &cli.Command{
+	Name:   "push",
+	Usage:  "Periodically pushes metrics to a Pushgateway or a Prometheus remote write endpoint.",
+	Action: runPush,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "push.url",
+			Usage:   "Base url of the Pushgateway or url of the remote write endpoint.",
+			EnvVars: []string{"PUSH_URL"},
+		},
+		&cli.StringFlag{
+			Name:    "push.mode",
+			Usage:   "Push mode, pushgateway replaces the metrics of the grouping key, remote-write uses the Prometheus remote write protocol.",
+			EnvVars: []string{"PUSH_MODE"},
+			Value:   push.ModePushgateway,
+		},
+		&cli.StringFlag{
+			Name:    "push.job",
+			Usage:   "Job of the grouping key, added as the job label in the remote-write mode.",
+			EnvVars: []string{"PUSH_JOB"},
+			Value:   collector.Name,
+		},
+		&cli.StringFlag{
+			Name:    "push.grouping-labels",
+			Usage:   "Labels of the grouping key, added to all series in the remote-write mode. Format: label1=value1 label2=value2",
+			EnvVars: []string{"PUSH_GROUPING_LABELS"},
+		},
+		&cli.DurationFlag{
+			Name:    "push.interval",
+			Usage:   "Interval between the pushes, 0 pushes the metrics once and exits.",
+			EnvVars: []string{"PUSH_INTERVAL"},
+			Value:   time.Second * 15,
+		},
+		&cli.IntFlag{
+			Name:    "push.retries",
+			Usage:   "Number of times a failed push is retried.",
+			EnvVars: []string{"PUSH_RETRIES"},
+			Value:   3,
+		},
+		&cli.DurationFlag{
+			Name:    "push.retry-interval",
+			Usage:   "Time waited before a failed push is retried.",
+			EnvVars: []string{"PUSH_RETRY_INTERVAL"},
+			Value:   time.Second,
+		},
+		&cli.DurationFlag{
+			Name:    "push.timeout",
+			Usage:   "Timeout of a single push request.",
+			EnvVars: []string{"PUSH_TIMEOUT"},
+			Value:   time.Second * 10,
+		},
+	},
+}
+
+func runPush(ctx *cli.Context) error {
+	cfg, err := config.LoadFromCLI(ctx)
+	if err != nil {
+		return err
+	}
+
+	grouping, err := config.ParseLabels(ctx.String("push.grouping-labels"))
+	if err != nil {
+		return fmt.Errorf("invalid push grouping labels: %v", err)
+	}
+	opts := push.Options{
+		Mode:          ctx.String("push.mode"),
+		URL:           ctx.String("push.url"),
+		Job:           ctx.String("push.job"),
+		Grouping:      grouping,
+		Interval:      ctx.Duration("push.interval"),
+		Retries:       ctx.Int("push.retries"),
+		RetryInterval: ctx.Duration("push.retry-interval"),
+		Timeout:       ctx.Duration("push.timeout"),
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	exps, closeStores, err := openExporters(ctx.Context, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStores()
+
+	reloader := collector.NewReloader(cfg, func() (config.Config, error) {
+		return config.LoadFromCLI(ctx)
+	}, exps...)
+	stopReload := reloadOnSignal(ctx.Context, reloader)
+	defer stopReload()
+
+	reg := collector.NewRegistry(exps...)
+	reg.MustRegister(reloader)
+
+	pusher, err := push.New(opts, reg)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Starting ", collector.Name, version.Info())
+	log.Println("Pushing metrics to", opts.URL, "using", opts.Mode)
+	log.Println("Build context", version.BuildContext())
+
+	if err := pusher.Run(ctx.Context); err != nil {
+		return fmt.Errorf("could not push metrics: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jbub/pgbouncer_exporter/internal/pgbouncertest"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushEndToEnd(t *testing.T) {
+	fixture, err := pgbouncertest.LoadFixture("1.24")
+	require.NoError(t, err)
+
+	srv := pgbouncertest.NewServer(fixture)
+	defer srv.Close() //nolint:errcheck
+
+	var (
+		path string
+		body []byte
+	)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path = req.URL.Path
+		body, _ = io.ReadAll(req.Body)
+	}))
+	defer gateway.Close()
+
+	wait := runApp(t, Push,
+		"--database-url", srv.URL(), "--default-labels", "instance=pg1",
+		"push", "--push.url", gateway.URL, "--push.grouping-labels", "env=ci", "--push.interval", "0",
+	)
+	_, err = wait()
+	require.NoError(t, err)
+
+	require.Equal(t, "/metrics/job/pgbouncer_exporter/env/ci", path)
+
+	dec := expfmt.NewDecoder(bytes.NewReader(body), expfmt.NewFormat(expfmt.TypeProtoDelim))
+	for {
+		var mf dto.MetricFamily
+		require.NoError(t, dec.Decode(&mf))
+		if mf.GetName() != "pgbouncer_exporter_totals_xacts_total" {
+			continue
+		}
+		require.Len(t, mf.GetMetric(), 1)
+		require.Equal(t, fixtureTotalXacts, mf.GetMetric()[0].GetCounter().GetValue())
+		return
+	}
+}
@@ -2,9 +2,12 @@ package cmd
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"github.com/jbub/pgbouncer_exporter/internal/collector"
 	"github.com/jbub/pgbouncer_exporter/internal/config"
@@ -23,33 +26,149 @@ var Server = &cli.Command{
 }
 
 func runServer(ctx *cli.Context) error {
-	cfg := config.LoadFromCLI(ctx)
-
-	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	cfg, err := config.LoadFromCLI(ctx)
 	if err != nil {
-		return fmt.Errorf("could not open db: %v", err)
+		return err
 	}
-	defer db.Close() //nolint:errcheck
-
-	store := sqlstore.New(db)
-
-	checkCtx, cancel := context.WithTimeout(context.Background(), cfg.StoreTimeout)
-	defer cancel()
 
-	if err := store.Check(checkCtx); err != nil {
-		return fmt.Errorf("could not check store: %v", err)
+	exps, closeStores, err := openExporters(ctx.Context, cfg)
+	if err != nil {
+		return err
 	}
+	defer closeStores()
+
+	reloader := collector.NewReloader(cfg, func() (config.Config, error) {
+		return config.LoadFromCLI(ctx)
+	}, exps...)
+	stopReload := reloadOnSignal(ctx.Context, reloader)
+	defer stopReload()
 
-	exp := collector.New(cfg, store)
-	srv := server.New(cfg, exp)
+	srv := server.New(cfg, reloader, exps...)
 
 	log.Println("Starting ", collector.Name, version.Info())
 	log.Println("Server listening on", cfg.ListenAddress)
 	log.Println("Metrics available at", cfg.TelemetryPath)
 	log.Println("Build context", version.BuildContext())
 
-	if err := srv.Run(); err != nil {
+	if err := srv.Run(ctx.Context); err != nil {
 		return fmt.Errorf("could not run server: %v", err)
 	}
 	return nil
 }
+
+// openExporters validates cfg and returns exporters of all targets using the configured store
+// and a function which closes their stores. The stores are watched until ctx is done or they are closed.
+func openExporters(ctx context.Context, cfg config.Config) ([]*collector.Exporter, func(), error) {
+	for _, target := range cfg.Targets {
+		if err := collector.ValidateConfig(cfg.WithTarget(target)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	switch cfg.Store {
+	case config.StorePgx:
+		return newPgxExporters(cfg)
+	case config.StoreLog:
+		return newLogExporters(ctx, cfg)
+	}
+	return newSQLExporters(ctx, cfg)
+}
+
+// newSQLExporters returns exporters of all targets and a function which stops the credential
+// watchers and closes their stores.
+func newSQLExporters(ctx context.Context, cfg config.Config) ([]*collector.Exporter, func(), error) {
+	exps := make([]*collector.Exporter, 0, len(cfg.Targets))
+	stores := make([]*sqlstore.Store, 0, len(cfg.Targets))
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	closeStores := func() {
+		cancel()
+		wg.Wait()
+		for _, store := range stores {
+			_ = store.Close()
+		}
+	}
+
+	for _, target := range cfg.Targets {
+		store, dsn, err := openStore(cfg, target)
+		if err != nil {
+			closeStores()
+			return nil, nil, err
+		}
+		stores = append(stores, store)
+
+		if target.WatchesCredentials() && cfg.CredentialsReloadInterval > 0 {
+			watcher := sqlstore.NewWatcher(store, dsn, target.DataSourceName, cfg.CredentialsReloadInterval)
+			wg.Go(func() { watcher.Run(watchCtx) })
+		}
+
+		exps = append(exps, collector.New(cfg.WithTarget(target), store))
+	}
+	return exps, closeStores, nil
+}
+
+// newPgxExporters returns exporters of all targets using the pgx store and a function which closes their stores.
+// Credentials are resolved on every connect, so no watcher is needed.
+func newPgxExporters(cfg config.Config) ([]*collector.Exporter, func(), error) {
+	exps := make([]*collector.Exporter, 0, len(cfg.Targets))
+	stores := make([]*sqlstore.PgxStore, 0, len(cfg.Targets))
+	closeStores := func() {
+		for _, store := range stores {
+			_ = store.Close()
+		}
+	}
+
+	for _, target := range cfg.Targets {
+		store, err := openPgxStore(cfg, target)
+		if err != nil {
+			closeStores()
+			return nil, nil, err
+		}
+		stores = append(stores, store)
+
+		exps = append(exps, collector.New(cfg.WithTarget(target), store))
+	}
+	return exps, closeStores, nil
+}
+
+// newLogExporters returns the exporter reading the pgbouncer log and a function which stops reading it.
+func newLogExporters(ctx context.Context, cfg config.Config) ([]*collector.Exporter, func(), error) {
+	store, stop, err := openLogStore(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []*collector.Exporter{collector.New(cfg.WithTarget(cfg.Targets[0]), store)}, stop, nil
+}
+
+// reloadOnSignal reloads the config on SIGHUP until ctx is done, the returned function
+// stops the reloading and waits for the reload in progress to finish.
+func reloadOnSignal(ctx context.Context, reloader *collector.Reloader) func() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer signal.Stop(ch)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				if err := reloader.Reload(); err != nil {
+					log.Printf("could not reload config: %v", err)
+					continue
+				}
+				log.Println("Config reloaded")
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/pgbouncertest"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// fixtureTotalXacts is the total_xact_count returned by SHOW TOTALS of the 1.24 fixture.
+const fixtureTotalXacts = 1507.0
+
+var (
+	serverStores = []string{
+		config.StoreSQL,
+		config.StorePgx,
+	}
+)
+
+func freeAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	return addr
+}
+
+// runApp runs the app with the command and args in the background, the returned function waits until
+// the command finishes and returns its output. The command is stopped when the test finishes unless
+// the test waited for it, it must stop without an error.
+func runApp(t *testing.T, cmd *cli.Command, args ...string) (wait func() (string, error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var out bytes.Buffer
+	app := &cli.App{
+		Flags:     Flags,
+		Commands:  []*cli.Command{cmd},
+		Writer:    &out,
+		ErrWriter: &out,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- app.RunContext(ctx, append([]string{"pgbouncer_exporter"}, args...))
+	}()
+
+	var (
+		waited bool
+		err    error
+	)
+	wait = func() (string, error) {
+		if !waited {
+			err = <-errc
+			waited = true
+		}
+		return out.String(), err
+	}
+	t.Cleanup(func() {
+		cancel()
+		if !waited {
+			_, err := wait()
+			require.NoError(t, err)
+		}
+	})
+	return wait
+}
+
+// startServer runs the server command with args until the test finishes and returns its listen address.
+func startServer(t *testing.T, args ...string) string {
+	addr := freeAddr(t)
+	args = append([]string{"--web.listen-address", addr}, args...)
+	runApp(t, Server, append(args, "server")...)
+	return addr
+}
+
+func scrape(t *testing.T, url string) string {
+	var body string
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close() //nolint:errcheck
+
+		data, err := io.ReadAll(resp.Body)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			return false
+		}
+		body = string(data)
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	return body
+}
+
+func TestServerEndToEnd(t *testing.T) {
+	fixture, err := pgbouncertest.LoadFixture("1.24")
+	require.NoError(t, err)
+
+	srv := pgbouncertest.NewServer(fixture)
+	defer srv.Close() //nolint:errcheck
+
+	for _, store := range serverStores {
+		t.Run(store, func(t *testing.T) {
+			addr := startServer(t, "--database-url", srv.URL(), "--store", store, "--default-labels", "instance=pg1")
+			body := scrape(t, "http://"+addr+"/metrics")
+
+			require.Contains(t, body, `pgbouncer_exporter_stats_total_xact_count{database="app",instance="pg1"} 1500`)
+			require.Contains(t, body, `pgbouncer_exporter_pools_active_clients{database="app",instance="pg1",pool_mode="transaction",user="app"} 12`)
+			require.Contains(t, body, `pgbouncer_exporter_pools_server_utilization{database="app",instance="pg1",pool_mode="transaction",user="app"} 0.4`)
+			require.Contains(t, body, `pgbouncer_exporter_database_pool_size{instance="pg1",name="app",pool_mode="transaction"} 20`)
+			require.Contains(t, body, `pgbouncer_exporter_lists_items{instance="pg1",list="free_clients"} 47`)
+			require.Contains(t, body, `pgbouncer_exporter_totals_xacts_total{instance="pg1"} 1507`)
+		})
+	}
+}
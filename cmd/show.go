@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/collector"
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/show"
+
+	"github.com/urfave/cli/v2"
+)
+
+// clearScreen moves the cursor to the top left corner and clears the terminal.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// Show is a cli command used for printing the admin console rows for humans.
+var Show = &cli.Command{
+	Name:      "show",
+	Usage:     "Prints the rows of the admin console of the enabled or given collectors.",
+	ArgsUsage: "[collector...]",
+	Action:    runShow,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "Output format, one of table, json or csv.",
+			Value:   show.FormatTable,
+		},
+		&cli.BoolFlag{
+			Name:    "watch",
+			Aliases: []string{"w"},
+			Usage:   "Refresh the output every interval, changed cells of tables are highlighted.",
+		},
+		&cli.DurationFlag{
+			Name:  "interval",
+			Usage: "Interval between the refreshes in the watch mode.",
+			Value: time.Second * 2,
+		},
+		&cli.StringSliceFlag{
+			Name:  "database",
+			Usage: "Show only the rows of the database, can be repeated.",
+		},
+		&cli.StringSliceFlag{
+			Name:  "user",
+			Usage: "Show only the rows of the user, can be repeated.",
+		},
+	},
+}
+
+func runShow(ctx *cli.Context) error {
+	cfg, err := config.LoadFromCLI(ctx)
+	if err != nil {
+		return err
+	}
+
+	watch := ctx.Bool("watch")
+	interval := ctx.Duration("interval")
+	if watch && interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+
+	format := ctx.String("output")
+	printer, err := show.NewPrinter(ctx.App.Writer, ctx.App.ErrWriter, format, watch)
+	if err != nil {
+		return err
+	}
+
+	exps, closeStores, err := openExporters(ctx.Context, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStores()
+
+	if collectors := ctx.Args().Slice(); len(collectors) > 0 {
+		for i, exp := range exps {
+			if exps[i], err = exp.Select(collectors); err != nil {
+				return err
+			}
+		}
+	}
+
+	filter := collector.SnapshotFilter{
+		Databases: ctx.StringSlice("database"),
+		Users:     ctx.StringSlice("user"),
+	}
+
+	for {
+		doc := show.Document{Timestamp: time.Now()}
+		for _, exp := range exps {
+			doc.Targets = append(doc.Targets, exp.Snapshot(ctx.Context, filter))
+		}
+
+		if watch && format == show.FormatTable {
+			_, _ = fmt.Fprintf(ctx.App.Writer, "%vEvery %v: %v\n\n", clearScreen, interval, doc.Timestamp.Format(time.DateTime))
+		}
+		if err := printer.Print(doc); err != nil {
+			return fmt.Errorf("could not print rows: %v", err)
+		}
+		if !watch {
+			return nil
+		}
+
+		select {
+		case <-ctx.Context.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
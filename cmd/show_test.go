@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jbub/pgbouncer_exporter/internal/pgbouncertest"
+	"github.com/jbub/pgbouncer_exporter/internal/show"
+
+	"github.com/stretchr/testify/require"
+)
+
+// showFixture runs the show command with args against the 1.24 fixture and returns its output.
+func showFixture(t *testing.T, args ...string) (string, error) {
+	fixture, err := pgbouncertest.LoadFixture("1.24")
+	require.NoError(t, err)
+
+	srv := pgbouncertest.NewServer(fixture)
+	defer srv.Close() //nolint:errcheck
+
+	wait := runApp(t, Show, append([]string{"--database-url", srv.URL(), "--default-labels", "instance=pg1", "show"}, args...)...)
+	return wait()
+}
+
+func TestShowTable(t *testing.T) {
+	out, err := showFixture(t, "--database", "app", "pools", "lists")
+	require.NoError(t, err)
+
+	require.Contains(t, out, "pools (instance=pg1)\n")
+	require.Contains(t, out, "lists (instance=pg1)\n")
+	require.NotContains(t, out, "stats (instance=pg1)")
+	require.Regexp(t, `(?m)^app\s+app\s+12\s+`, out)
+	require.NotContains(t, out, "pgbouncer  pgbouncer")
+}
+
+func TestShowJSON(t *testing.T) {
+	out, err := showFixture(t, "--output", "json", "totals")
+	require.NoError(t, err)
+
+	var doc show.Document
+	require.NoError(t, json.Unmarshal([]byte(out), &doc))
+	require.Len(t, doc.Targets, 1)
+	require.Equal(t, fixtureTotalXacts, doc.Targets[0].Collectors["totals"].Rows[0]["total_xact_count"])
+}
+
+func TestShowUnknownCollector(t *testing.T) {
+	_, err := showFixture(t, "clients")
+	require.EqualError(t, err, `unknown collector "clients"`)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/collector"
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/statsd"
+
+	"github.com/prometheus/common/version"
+	"github.com/urfave/cli/v2"
+)
+
+// StatsD is a cli command used for sending metrics to a StatsD or DogStatsD server.
+var StatsD = &cli.Command{
+	Name:   "statsd",
+	Usage:  "Periodically sends metrics over UDP in the StatsD or DogStatsD format.",
+	Action: runStatsD,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "statsd.address",
+			Usage:   "Address of the StatsD server.",
+			EnvVars: []string{"STATSD_ADDRESS"},
+			Value:   "127.0.0.1:8125",
+		},
+		&cli.StringFlag{
+			Name:    "statsd.format",
+			Usage:   "Format of the metrics, dogstatsd sends the labels as tags, statsd appends them to the metric name.",
+			EnvVars: []string{"STATSD_FORMAT"},
+			Value:   statsd.FormatDogStatsD,
+		},
+		&cli.DurationFlag{
+			Name:    "statsd.interval",
+			Usage:   "Interval between the sends.",
+			EnvVars: []string{"STATSD_INTERVAL"},
+			Value:   time.Second * 10,
+		},
+		&cli.IntFlag{
+			Name:    "statsd.max-packet-size",
+			Usage:   "Maximum size of a single UDP packet in bytes.",
+			EnvVars: []string{"STATSD_MAX_PACKET_SIZE"},
+			Value:   1432,
+		},
+	},
+}
+
+func runStatsD(ctx *cli.Context) error {
+	cfg, err := config.LoadFromCLI(ctx)
+	if err != nil {
+		return err
+	}
+
+	opts := statsd.Options{
+		Address:       ctx.String("statsd.address"),
+		Format:        ctx.String("statsd.format"),
+		Interval:      ctx.Duration("statsd.interval"),
+		MaxPacketSize: ctx.Int("statsd.max-packet-size"),
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	exps, closeStores, err := openExporters(ctx.Context, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStores()
+
+	reloader := collector.NewReloader(cfg, func() (config.Config, error) {
+		return config.LoadFromCLI(ctx)
+	}, exps...)
+	stopReload := reloadOnSignal(ctx.Context, reloader)
+	defer stopReload()
+
+	reg := collector.NewExporterRegistry(exps...)
+	reg.MustRegister(reloader)
+
+	sender, err := statsd.New(opts, reg)
+	if err != nil {
+		return err
+	}
+	defer sender.Close() //nolint:errcheck
+
+	log.Println("Starting ", collector.Name, version.Info())
+	log.Println("Sending metrics to", opts.Address, "using", opts.Format)
+	log.Println("Build context", version.BuildContext())
+
+	if err := sender.Run(ctx.Context); err != nil {
+		return fmt.Errorf("could not send metrics: %v", err)
+	}
+	return nil
+}
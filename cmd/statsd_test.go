@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/pgbouncertest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsDEndToEnd(t *testing.T) {
+	fixture, err := pgbouncertest.LoadFixture("1.24")
+	require.NoError(t, err)
+
+	srv := pgbouncertest.NewServer(fixture)
+	defer srv.Close() //nolint:errcheck
+
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	runApp(t, StatsD,
+		"--database-url", srv.URL(), "--default-labels", "instance=pg1",
+		"statsd", "--statsd.address", listener.LocalAddr().String(), "--statsd.interval", "10ms",
+	)
+
+	// the second send contains the counters, their increase is 0 as the fixture does not change
+	lines := make(map[string]bool)
+	buf := make([]byte, 65536)
+	require.NoError(t, listener.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for !lines["pgbouncer_exporter_totals_xacts_total:0|c|#instance:pg1"] {
+		n, _, err := listener.ReadFrom(buf)
+		require.NoError(t, err)
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			lines[line] = true
+		}
+	}
+
+	require.True(t, lines["pgbouncer_exporter_pools_active_clients:12|g|#database:app,instance:pg1,pool_mode:transaction,user:app"])
+	require.True(t, lines["pgbouncer_exporter_stats_total_query_count:0|c|#database:app,instance:pg1"])
+	for line := range lines {
+		require.False(t, strings.HasPrefix(line, "go_") || strings.HasPrefix(line, "process_"), line)
+	}
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/logstore"
+	"github.com/jbub/pgbouncer_exporter/internal/mapping"
+	"github.com/jbub/pgbouncer_exporter/internal/sqlstore"
+)
+
+// openStore opens the store of the given target and checks its health.
+func openStore(cfg config.Config, target config.Target) (*sqlstore.Store, string, error) {
+	dsn, err := target.DataSourceName()
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid database config of target %v: %v", target, err)
+	}
+
+	db, err := sqlstore.Open(dsn)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not open db of target %v: %v", target, err)
+	}
+
+	store := sqlstore.New(db, cfg.StrictColumns, mapping.Columns(cfg.Mappings))
+
+	checkCtx, cancel := context.WithTimeout(context.Background(), cfg.StoreTimeout)
+	defer cancel()
+
+	if err := store.Check(checkCtx); err != nil {
+		_ = store.Close()
+		return nil, "", fmt.Errorf("could not check store of target %v: %v", target, err)
+	}
+	return store, dsn, nil
+}
+
+// openPgxStore returns the pgx store of the given target and checks its health.
+func openPgxStore(cfg config.Config, target config.Target) (*sqlstore.PgxStore, error) {
+	store := sqlstore.NewPgx(target.DataSourceName, cfg.StrictColumns, mapping.Columns(cfg.Mappings))
+
+	checkCtx, cancel := context.WithTimeout(context.Background(), cfg.StoreTimeout)
+	defer cancel()
+
+	if err := store.Check(checkCtx); err != nil {
+		_ = store.Close()
+		return nil, fmt.Errorf("could not check store of target %v: %v", target, err)
+	}
+	return store, nil
+}
+
+// logFollowInterval is the interval in which the followed log file is checked for new lines.
+const logFollowInterval = time.Second
+
+// openLogStore starts reading the pgbouncer log from the configured file, or stdin when it is "-",
+// until ctx is done and returns the store along with a function which stops reading. The reading
+// of stdin can not be interrupted, the function waits only for the reading of the file to stop.
+func openLogStore(ctx context.Context, cfg config.Config) (*logstore.Store, func(), error) {
+	store := logstore.New(cfg.LogStatsPeriod)
+
+	ctx, cancel := context.WithCancel(ctx)
+	if cfg.LogFile == "-" {
+		go store.Consume(ctx, os.Stdin)
+		return store, cancel, nil
+	}
+
+	follower, err := logstore.Follow(ctx, cfg.LogFile, logFollowInterval)
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("could not open log file: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		store.Consume(ctx, follower)
+		_ = follower.Close()
+	}()
+	return store, func() {
+		cancel()
+		<-done
+	}, nil
+}
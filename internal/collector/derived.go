@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+)
+
+// poolDatabase represents a pool joined with the database it belongs to,
+// database is nil when the database row is missing.
+type poolDatabase struct {
+	pool     domain.Pool
+	database *domain.Database
+}
+
+// joinPoolsDatabases joins pools with databases using the database name.
+func joinPoolsDatabases(pools []domain.Pool, databases []domain.Database) []poolDatabase {
+	byName := make(map[string]*domain.Database, len(databases))
+	for i := range databases {
+		byName[databases[i].Name] = &databases[i]
+	}
+
+	joined := make([]poolDatabase, 0, len(pools))
+	for _, pool := range pools {
+		joined = append(joined, poolDatabase{
+			pool:     pool,
+			database: byName[pool.Database],
+		})
+	}
+	return joined
+}
+
+// databaseClients represents the number of active and waiting clients of all pools of a database,
+// database is nil when the database row is missing.
+type databaseClients struct {
+	name     string
+	database *domain.Database
+	clients  int64
+}
+
+// sumDatabaseClients sums the active and waiting clients of the joined pools per database,
+// databases are returned in the order of their first pool.
+func sumDatabaseClients(joined []poolDatabase) []databaseClients {
+	index := make(map[string]int, len(joined))
+	var sums []databaseClients
+	for _, row := range joined {
+		i, ok := index[row.pool.Database]
+		if !ok {
+			i = len(sums)
+			index[row.pool.Database] = i
+			sums = append(sums, databaseClients{name: row.pool.Database, database: row.database})
+		}
+		sums[i].clients += row.pool.Active + row.pool.Waiting
+	}
+	return sums
+}
+
+// ratio returns a divided by b, ok is false when b is not positive.
+func ratio(a int64, b int64) (value float64, ok bool) {
+	if b <= 0 {
+		return 0, false
+	}
+	return float64(a) / float64(b), true
+}
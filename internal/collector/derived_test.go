@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinPoolsDatabases(t *testing.T) {
+	pools := []domain.Pool{
+		{Database: "main", User: "app"},
+		{Database: "main", User: "admin"},
+		{Database: "removed", User: "app"},
+	}
+	databases := []domain.Database{
+		{Name: "main", PoolSize: 20},
+		{Name: "unused", PoolSize: 10},
+	}
+
+	joined := joinPoolsDatabases(pools, databases)
+	require.Len(t, joined, 3)
+
+	require.Equal(t, pools[0], joined[0].pool)
+	require.NotNil(t, joined[0].database)
+	require.Equal(t, "main", joined[0].database.Name)
+
+	require.Equal(t, pools[1], joined[1].pool)
+	require.Same(t, joined[0].database, joined[1].database)
+
+	require.Equal(t, pools[2], joined[2].pool)
+	require.Nil(t, joined[2].database)
+}
+
+func TestJoinPoolsDatabasesEmpty(t *testing.T) {
+	require.Empty(t, joinPoolsDatabases(nil, []domain.Database{{Name: "main"}}))
+
+	joined := joinPoolsDatabases([]domain.Pool{{Database: "main"}}, nil)
+	require.Len(t, joined, 1)
+	require.Nil(t, joined[0].database)
+}
+
+func TestSumDatabaseClients(t *testing.T) {
+	main := &domain.Database{Name: "main", MaxClientConnections: 100}
+	joined := []poolDatabase{
+		{pool: domain.Pool{Database: "main", User: "app", Active: 8, Waiting: 2}, database: main},
+		{pool: domain.Pool{Database: "removed", User: "app", Active: 1, Waiting: 1}},
+		{pool: domain.Pool{Database: "main", User: "admin", Active: 5, Waiting: 5}, database: main},
+	}
+
+	sums := sumDatabaseClients(joined)
+	require.Equal(t, []databaseClients{
+		{name: "main", database: main, clients: 20},
+		{name: "removed", clients: 2},
+	}, sums)
+
+	require.Empty(t, sumDatabaseClients(nil))
+}
+
+func TestDerivedMetrics(t *testing.T) {
+	st := &testStore{
+		pools: []domain.Pool{
+			{Database: "main", User: "app", PoolMode: "transaction", Active: 8, Waiting: 2, ServerActive: 5},
+			{Database: "main", User: "admin", PoolMode: "transaction", Active: 5, Waiting: 5, ServerActive: 2},
+			{Database: "idle", User: "app", PoolMode: "transaction", Active: 0, Waiting: 0, ServerActive: 0},
+			{Database: "unlimited", User: "app", PoolMode: "session", Active: 4, Waiting: 0, ServerActive: 4},
+			{Database: "removed", User: "app", PoolMode: "session", Active: 1, Waiting: 1, ServerActive: 1},
+			{Database: "saturated", User: "app", PoolMode: "session", Active: 0, Waiting: 3, ServerActive: 0},
+		},
+		databases: []domain.Database{
+			{Name: "main", PoolSize: 20, MaxClientConnections: 100},
+			{Name: "idle", PoolSize: 10, MaxClientConnections: 10},
+			{Name: "unlimited", PoolSize: 0, MaxClientConnections: 0},
+		},
+	}
+
+	cfg := config.Config{
+		StoreTimeout:    time.Second,
+		ExportPools:     true,
+		ExportDatabases: true,
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(New(cfg, st))
+
+	expected := `
+# HELP pgbouncer_exporter_pools_client_utilization Ratio of active and waiting client connections of all pools of the database to its max_client_connections, not exported when the database is missing or the limit is not set.
+# TYPE pgbouncer_exporter_pools_client_utilization gauge
+pgbouncer_exporter_pools_client_utilization{database="idle"} 0
+pgbouncer_exporter_pools_client_utilization{database="main"} 0.2
+# HELP pgbouncer_exporter_pools_client_waiting_ratio Ratio of waiting client connections to active client connections, not exported when there are no active clients.
+# TYPE pgbouncer_exporter_pools_client_waiting_ratio gauge
+pgbouncer_exporter_pools_client_waiting_ratio{database="main",pool_mode="transaction",user="admin"} 1
+pgbouncer_exporter_pools_client_waiting_ratio{database="main",pool_mode="transaction",user="app"} 0.25
+pgbouncer_exporter_pools_client_waiting_ratio{database="removed",pool_mode="session",user="app"} 1
+pgbouncer_exporter_pools_client_waiting_ratio{database="unlimited",pool_mode="session",user="app"} 0
+# HELP pgbouncer_exporter_pools_server_utilization Ratio of active server connections to the pool_size of the database, not exported when the database is missing or its pool_size is 0.
+# TYPE pgbouncer_exporter_pools_server_utilization gauge
+pgbouncer_exporter_pools_server_utilization{database="idle",pool_mode="transaction",user="app"} 0
+pgbouncer_exporter_pools_server_utilization{database="main",pool_mode="transaction",user="admin"} 0.1
+pgbouncer_exporter_pools_server_utilization{database="main",pool_mode="transaction",user="app"} 0.25
+`
+
+	err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"pgbouncer_exporter_pools_client_utilization",
+		"pgbouncer_exporter_pools_client_waiting_ratio",
+		"pgbouncer_exporter_pools_server_utilization",
+	)
+	require.NoError(t, err)
+}
+
+func TestDerivedMetricsDatabasesDisabled(t *testing.T) {
+	cfg := config.Config{
+		ExportPools:     true,
+		ExportDatabases: false,
+	}
+
+	for _, met := range buildMetrics(cfg) {
+		switch met.name {
+		case fqName(SubsystemPools, "server_utilization"), fqName(SubsystemPools, "client_utilization"):
+			require.False(t, met.enabled, met.name)
+		case fqName(SubsystemPools, "client_waiting_ratio"):
+			require.True(t, met.enabled, met.name)
+		}
+	}
+}
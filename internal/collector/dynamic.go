@@ -0,0 +1,211 @@
+package collector
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+	"github.com/jbub/pgbouncer_exporter/internal/mapping"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricNameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// dynamicSource describes the rows of a collector whose extra values are exported
+// as pgbouncer_exporter_<subsystem>_<column> with the same identity labels as the known metrics,
+// columns used by the mappings are exported only as defined by the mappings.
+type dynamicSource struct {
+	enabled   bool
+	collector string
+	subsystem string
+	command   string
+	labels    []string
+	rows      func(res *storeResult) []dynamicRow
+}
+
+type dynamicRow struct {
+	labels []string
+	values domain.Values
+}
+
+func buildDynamicSources(cfg config.Config) []dynamicSource {
+	return []dynamicSource{
+		{
+			enabled:   cfg.ExportStats,
+			collector: config.CollectorStats,
+			subsystem: SubsystemStats,
+			command:   domain.CommandStats,
+			labels:    []string{"database"},
+			rows: func(res *storeResult) (rows []dynamicRow) {
+				for _, stat := range res.stats {
+					rows = append(rows, dynamicRow{
+						labels: []string{stat.Database},
+						values: stat.Extra.Values,
+					})
+				}
+				return rows
+			},
+		},
+		{
+			enabled:   cfg.ExportPools,
+			collector: config.CollectorPools,
+			subsystem: SubsystemPools,
+			command:   domain.CommandPools,
+			labels:    []string{"database", "user", "pool_mode"},
+			rows: func(res *storeResult) (rows []dynamicRow) {
+				for _, pool := range res.pools {
+					rows = append(rows, dynamicRow{
+						labels: []string{pool.Database, pool.User, pool.PoolMode},
+						values: pool.Extra.Values,
+					})
+				}
+				return rows
+			},
+		},
+		{
+			enabled:   cfg.ExportDatabases,
+			collector: config.CollectorDatabases,
+			subsystem: SubsystemDatabases,
+			command:   domain.CommandDatabases,
+			labels:    []string{"name", "pool_mode"},
+			rows: func(res *storeResult) (rows []dynamicRow) {
+				for _, database := range res.databases {
+					rows = append(rows, dynamicRow{
+						labels: []string{database.Name, database.PoolMode},
+						values: database.Extra.Values,
+					})
+				}
+				return rows
+			},
+		},
+		{
+			enabled:   cfg.ExportLists,
+			collector: config.CollectorLists,
+			subsystem: SubsystemLists,
+			command:   domain.CommandLists,
+			labels:    []string{"list"},
+			rows: func(res *storeResult) (rows []dynamicRow) {
+				for _, list := range res.lists {
+					rows = append(rows, dynamicRow{
+						labels: []string{list.List},
+						values: list.Extra.Values,
+					})
+				}
+				return rows
+			},
+		},
+		{
+			enabled:   cfg.ExportTotals,
+			collector: config.CollectorTotals,
+			subsystem: SubsystemTotals,
+			command:   domain.CommandTotals,
+			rows: func(res *storeResult) []dynamicRow {
+				if res.totals == nil {
+					return nil
+				}
+				return []dynamicRow{{values: res.totals.Extra.Values}}
+			},
+		},
+	}
+}
+
+// dynamicMetric is a metric built on demand for a column seen in the store result.
+type dynamicMetric struct {
+	metric
+	desc *prometheus.Desc
+}
+
+// dynamicMetrics caches the metrics built for the columns by name. The Exporter is an unchecked
+// collector, so the descriptors are not registered upfront, but they have to stay the same
+// across scrapes, and invalid ones are disabled instead of failing the whole scrape.
+type dynamicMetrics struct {
+	cfg         config.Config
+	sources     []dynamicSource
+	constLabels prometheus.Labels
+	known       map[string]struct{}
+	mapped      map[domain.Column]struct{}
+	metrics     map[string]dynamicMetric
+}
+
+func newDynamicMetrics(cfg config.Config, constLabels prometheus.Labels, metrics []metric) *dynamicMetrics {
+	known := make(map[string]struct{}, len(metrics))
+	for _, met := range metrics {
+		known[met.name] = struct{}{}
+	}
+	mapped := make(map[domain.Column]struct{})
+	for _, col := range mapping.Columns(mappings(cfg)) {
+		mapped[col] = struct{}{}
+	}
+	return &dynamicMetrics{
+		cfg:         cfg,
+		sources:     buildDynamicSources(cfg),
+		constLabels: constLabels,
+		known:       known,
+		mapped:      mapped,
+		metrics:     make(map[string]dynamicMetric),
+	}
+}
+
+func (d *dynamicMetrics) get(src dynamicSource, column string) dynamicMetric {
+	name := fqName(src.subsystem, column)
+	if met, ok := d.metrics[name]; ok {
+		return met
+	}
+
+	met := dynamicMetric{
+		metric: metric{
+			enabled:   true,
+			collector: src.collector,
+			name:      name,
+			help:      fmt.Sprintf("Value of the %v column returned by %v, the column is not known to the exporter.", column, src.command),
+			labels:    src.labels,
+			valType:   prometheus.GaugeValue,
+		},
+	}
+
+	switch _, known := d.known[name]; {
+	case !metricNameRe.MatchString(name):
+		log.Printf("Not exporting column %v returned by %v, %v is not a valid metric name", column, src.command, name)
+		met.enabled = false
+	case known:
+		log.Printf("Not exporting column %v returned by %v, metric %v already exists", column, src.command, name)
+		met.enabled = false
+	default:
+		met.metric = applyFilters([]metric{met.metric}, d.cfg)[0]
+		met.desc = met.metric.desc(d.constLabels)
+	}
+
+	d.metrics[name] = met
+	return met
+}
+
+func (d *dynamicMetrics) collect(ch chan<- prometheus.Metric, res *storeResult) {
+	for _, src := range d.sources {
+		if !src.enabled {
+			continue
+		}
+
+		for _, row := range src.rows(res) {
+			for column, value := range row.values {
+				if _, ok := d.mapped[domain.Column{Command: src.command, Name: column}]; ok {
+					continue
+				}
+
+				met := d.get(src, column)
+				if !met.enabled || !met.matchLabels(row.labels) {
+					continue
+				}
+
+				m, err := prometheus.NewConstMetric(met.desc, met.valType, value, row.labels...)
+				if err != nil {
+					log.Printf("could not export column %v returned by %v: %v", column, src.command, err)
+					continue
+				}
+				ch <- m
+			}
+		}
+	}
+}
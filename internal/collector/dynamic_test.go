@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectDynamicMetrics(t *testing.T) {
+	st := &testStore{
+		stats: []domain.Stat{
+			{Database: "main", Extra: domain.Row{Values: domain.Values{"total_new_count": 5}}},
+		},
+		pools: []domain.Pool{
+			{Database: "main", User: "app", PoolMode: "session", Extra: domain.Row{Values: domain.Values{"sv_new": 1, "sv-invalid": 2}}},
+			{Database: "main", User: "admin", PoolMode: "session", Extra: domain.Row{Values: domain.Values{"sv_new": 3}}},
+			{Database: "test", User: "app", PoolMode: "session", Extra: domain.Row{Values: domain.Values{"sv_new": 4}}},
+		},
+		databases: []domain.Database{
+			{Name: "main", PoolMode: "session", Extra: domain.Row{Values: domain.Values{"new_limit": 6}}},
+		},
+		lists: []domain.List{
+			{List: "pools", Items: 1, Extra: domain.Row{Values: domain.Values{"peak": 7}}},
+		},
+	}
+
+	cfg := config.Config{
+		StoreTimeout:    time.Second,
+		ExportStats:     true,
+		ExportPools:     true,
+		ExportDatabases: true,
+		ExportLists:     false,
+		Filters: config.Filters{
+			Metrics: mustCompileFilter(nil, []string{"pgbouncer_exporter_database_.*"}),
+			Users:   mustCompileFilter(nil, []string{"admin"}),
+		},
+		CollectorFilters: map[string]config.Filters{
+			config.CollectorPools: {
+				Databases: mustCompileFilter([]string{"main"}, nil),
+			},
+		},
+		DefaultLabels: map[string]string{"env": "dev"},
+	}
+
+	exp := New(cfg, st)
+
+	expected := `
+# HELP pgbouncer_exporter_pools_sv_new Value of the sv_new column returned by SHOW POOLS, the column is not known to the exporter.
+# TYPE pgbouncer_exporter_pools_sv_new gauge
+pgbouncer_exporter_pools_sv_new{database="main",env="dev",pool_mode="session",user="app"} 1
+# HELP pgbouncer_exporter_stats_total_new_count Value of the total_new_count column returned by SHOW STATS, the column is not known to the exporter.
+# TYPE pgbouncer_exporter_stats_total_new_count gauge
+pgbouncer_exporter_stats_total_new_count{database="main",env="dev"} 5
+`
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(exp)
+
+	for range 2 {
+		err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+			"pgbouncer_exporter_stats_total_new_count",
+			"pgbouncer_exporter_pools_sv_new",
+			"pgbouncer_exporter_pools_sv-invalid",
+			"pgbouncer_exporter_database_new_limit",
+			"pgbouncer_exporter_lists_peak",
+		)
+		require.NoError(t, err)
+	}
+}
+
+func TestDynamicMetricsKnownName(t *testing.T) {
+	cfg := config.Config{ExportStats: true}
+	metrics := []metric{{enabled: true, name: "pgbouncer_exporter_stats_total_sent"}}
+	dynamic := newDynamicMetrics(cfg, nil, metrics)
+
+	src := dynamic.sources[0]
+	require.False(t, dynamic.get(src, "total_sent").enabled)
+	require.True(t, dynamic.get(src, "total_received").enabled)
+	require.Len(t, dynamic.metrics, 2)
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strings"
+	"maps"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/jbub/pgbouncer_exporter/internal/config"
 	"github.com/jbub/pgbouncer_exporter/internal/domain"
@@ -24,6 +26,7 @@ const (
 	SubsystemPools     = "pools"
 	SubsystemDatabases = "database"
 	SubsystemLists     = "lists"
+	SubsystemTotals    = "totals"
 )
 
 var (
@@ -31,12 +34,14 @@ var (
 )
 
 type metric struct {
-	enabled bool
-	name    string
-	help    string
-	labels  []string
-	valType prometheus.ValueType
-	eval    func(res *storeResult) []metricResult
+	enabled      bool
+	collector    string
+	name         string
+	help         string
+	labels       []string
+	valType      prometheus.ValueType
+	eval         func(res *storeResult) []metricResult
+	labelFilters []labelFilter
 }
 
 func (m metric) desc(constLabels prometheus.Labels) *prometheus.Desc {
@@ -53,37 +58,135 @@ type storeResult struct {
 	pools     []domain.Pool
 	databases []domain.Database
 	lists     []domain.List
+	totals    *domain.Totals
+	totalsErr error
+
+	unknownColumns []domain.UnknownColumn
+	queries        []queryResult
+	databaseCounts inventoryCounts
+	poolCounts     inventoryCounts
+
+	statsSnapshot
+}
+
+// queryResult is the result of a custom query, err is set when the query failed.
+type queryResult struct {
+	command string
+	rows    []domain.Row
+	err     error
+}
+
+// unknownColumnsStore is implemented by stores which ignore the columns they do not know.
+type unknownColumnsStore interface {
+	UnknownColumns() []domain.UnknownColumn
 }
 
 // Exporter represents pgbouncer prometheus stats exporter.
 type Exporter struct {
-	cfg         config.Config
 	stor        domain.Store
-	mut         sync.Mutex // guards Collect
+	mut         sync.Mutex // guards Collect and the fields below
+	cfg         config.Config
 	constLabels prometheus.Labels
 	metrics     []metric
+	dynamic     *dynamicMetrics
+	history     *statsHistory
+	inventory   *inventory
+	// totalsFailing is set while SHOW TOTALS fails, the failure is logged only when it starts
+	totalsFailing bool
+	// last is the result of the last Collect served by Snapshot, it is nil until the first Collect
+	last *scrapeResult
+}
+
+// scrapeResult represents the result of a Collect, err is set when reading the store failed.
+type scrapeResult struct {
+	res       *storeResult
+	err       error
+	timestamp time.Time
 }
 
 // New returns new Exporter.
 func New(cfg config.Config, stor domain.Store) *Exporter {
+	constLabels := buildConstLabels(cfg)
+	metrics := applyFilters(buildMetrics(cfg), cfg)
+
 	return &Exporter{
+		history:     new(statsHistory),
+		inventory:   new(inventory),
 		stor:        stor,
 		cfg:         cfg,
-		constLabels: parseLabels(cfg.DefaultLabels),
-		metrics:     buildMetrics(cfg),
+		constLabels: constLabels,
+		metrics:     metrics,
+		dynamic:     newDynamicMetrics(cfg, constLabels, metrics),
 	}
 }
 
-// Describe implements prometheus Collector.Describe.
-func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	for _, met := range e.metrics {
-		if !met.enabled {
+// ApplyConfig atomically replaces the configuration and the set of exported metrics.
+func (e *Exporter) ApplyConfig(cfg config.Config) {
+	constLabels := buildConstLabels(cfg)
+	metrics := applyFilters(buildMetrics(cfg), cfg)
+	dynamic := newDynamicMetrics(cfg, constLabels, metrics)
+
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	e.cfg = cfg
+	e.constLabels = constLabels
+	e.metrics = metrics
+	e.dynamic = dynamic
+	// the last result was read using the previous collectors and queries
+	e.last = nil
+}
+
+// Select returns a new Exporter sharing the store which exports only the given collectors,
+// the collectors have to be enabled in the config. The custom queries are selected by config.CollectorQueries.
+func (e *Exporter) Select(collectors []string) (*Exporter, error) {
+	e.mut.Lock()
+	cfg := e.cfg
+	e.mut.Unlock()
+
+	enabled := map[string]*bool{
+		config.CollectorStats:     &cfg.ExportStats,
+		config.CollectorPools:     &cfg.ExportPools,
+		config.CollectorDatabases: &cfg.ExportDatabases,
+		config.CollectorLists:     &cfg.ExportLists,
+		config.CollectorTotals:    &cfg.ExportTotals,
+	}
+
+	for _, name := range collectors {
+		if name == config.CollectorQueries {
+			if len(cfg.Queries) == 0 {
+				return nil, fmt.Errorf("collector %q is disabled", name)
+			}
 			continue
 		}
-		ch <- met.desc(e.constLabels)
+		export, ok := enabled[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+		if !*export {
+			return nil, fmt.Errorf("collector %q is disabled", name)
+		}
+	}
+	for name, export := range enabled {
+		if !slices.Contains(collectors, name) {
+			*export = false
+		}
+	}
+	if !slices.Contains(collectors, config.CollectorQueries) {
+		cfg.Queries = nil
 	}
+	exp := New(cfg, e.stor)
+	exp.history = e.history
+	exp.inventory = e.inventory
+	return exp, nil
 }
 
+// Describe implements prometheus Collector.Describe.
+// No descriptors are sent which makes the Exporter an unchecked collector,
+// this allows the set of metrics and their labels to change on config reload.
+// The registry does not check the metrics, ValidateConfig does it instead.
+func (e *Exporter) Describe(chan<- *prometheus.Desc) {}
+
 // Collect implements prometheus Collector.Collect.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	e.mut.Lock()
@@ -93,6 +196,7 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	defer cancel()
 
 	res, err := e.getStoreResult(ctx)
+	e.last = &scrapeResult{res: res, err: err, timestamp: time.Now()}
 	if err != nil {
 		log.Printf("could not get store result: %v", err)
 		return
@@ -106,6 +210,9 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 		results := met.eval(res)
 
 		for _, res := range results {
+			if !met.matchLabels(res.labels) {
+				continue
+			}
 			ch <- prometheus.MustNewConstMetric(
 				met.desc(e.constLabels),
 				met.valType,
@@ -114,9 +221,47 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 			)
 		}
 	}
+
+	e.dynamic.collect(ch, res)
 }
 
+// getStoreResult reads the store and updates the state used to compute the metrics.
 func (e *Exporter) getStoreResult(ctx context.Context) (*storeResult, error) {
+	res, err := e.readStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.cfg.ExportStats {
+		res.statsSnapshot = e.history.observe(res.stats, time.Now())
+	}
+	if e.cfg.ExportPools {
+		res.poolCounts = e.inventory.observePools(res.pools)
+	}
+	if e.cfg.ExportDatabases {
+		res.databaseCounts = e.inventory.observeDatabases(res.databases)
+	}
+
+	// failing totals do not fail the scrape like the custom queries, the error is exported instead
+	if e.cfg.ExportTotals {
+		if res.totalsErr != nil && !e.totalsFailing {
+			log.Printf("could not get totals, not exporting them until they succeed: %v", res.totalsErr)
+		}
+		e.totalsFailing = res.totalsErr != nil
+	}
+
+	// failing custom queries do not fail the scrape, the errors are exported instead
+	for _, q := range res.queries {
+		if q.err != nil {
+			log.Printf("could not run query %v: %v", q.command, q.err)
+		}
+	}
+	return res, nil
+}
+
+// readStore reads the rows of the enabled collectors and of the custom queries from the store,
+// the errors of the totals and of the custom queries are returned in the result.
+func (e *Exporter) readStore(ctx context.Context) (*storeResult, error) {
 	res := new(storeResult)
 
 	if e.cfg.ExportStats {
@@ -151,23 +296,80 @@ func (e *Exporter) getStoreResult(ctx context.Context) (*storeResult, error) {
 		res.lists = lists
 	}
 
+	if e.cfg.ExportTotals {
+		res.totals, res.totalsErr = e.stor.GetTotals(ctx)
+	}
+
+	for _, q := range e.cfg.Queries {
+		rows, err := e.stor.Query(ctx, q.Command)
+		res.queries = append(res.queries, queryResult{command: q.Command, rows: rows, err: err})
+	}
+
+	if stor, ok := e.stor.(unknownColumnsStore); ok {
+		res.unknownColumns = stor.UnknownColumns()
+	}
+
 	return res, nil
 }
 
-func parseLabels(s string) prometheus.Labels {
-	if s == "" {
-		return nil
+// ValidateConfig validates the parts of cfg which depend on the exported metrics,
+// cfg has to be scoped to a single target.
+func ValidateConfig(cfg config.Config) error {
+	metrics := buildMetrics(cfg)
+	constLabels := buildConstLabels(cfg)
+	for _, name := range slices.Sorted(maps.Keys(constLabels)) {
+		for _, met := range metrics {
+			if slices.Contains(met.labels, name) {
+				return fmt.Errorf("constant label %q collides with the label of metric %v", name, met.name)
+			}
+		}
 	}
+	return checkMetrics(metrics, constLabels)
+}
 
-	items := strings.Split(s, " ")
-	res := make(prometheus.Labels, len(items))
-	for _, item := range items {
-		if item == "" {
-			continue
-		}
-		if parts := strings.SplitN(item, "=", 2); len(parts) == 2 {
-			res[parts[0]] = parts[1]
+// checkMetrics checks that the metrics do not collide with each other or with the metrics of the other
+// collectors registered by the exporter. The Exporter is an unchecked collector, without the check
+// the collisions would fail every scrape instead of the registration.
+func checkMetrics(metrics []metric, constLabels prometheus.Labels) error {
+	seen := make(map[string]struct{}, len(metrics))
+	descs := make(descCollector, 0, len(metrics))
+	for _, met := range metrics {
+		if _, ok := seen[met.name]; ok {
+			return fmt.Errorf("metric %v collides with a built-in metric", met.name)
 		}
+		seen[met.name] = struct{}{}
+		descs = append(descs, met.desc(constLabels))
+	}
+
+	reg := NewRegistry()
+	reg.MustRegister(NewReloader(config.Config{}, nil))
+	if err := reg.Register(descs); err != nil {
+		return fmt.Errorf("could not register metrics: %v", err)
+	}
+	return nil
+}
+
+// descCollector is a collector only describing the descriptors, it is used to check them using a registry.
+type descCollector []*prometheus.Desc
+
+// Describe implements prometheus Collector.Describe.
+func (c descCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range c {
+		ch <- desc
 	}
+}
+
+// Collect implements prometheus Collector.Collect.
+func (c descCollector) Collect(chan<- prometheus.Metric) {}
+
+// buildConstLabels merges labels from the config with default labels, default labels take precedence.
+func buildConstLabels(cfg config.Config) prometheus.Labels {
+	if len(cfg.Labels) == 0 && len(cfg.DefaultLabels) == 0 {
+		return nil
+	}
+
+	res := make(prometheus.Labels, len(cfg.Labels)+len(cfg.DefaultLabels))
+	maps.Copy(res, cfg.Labels)
+	maps.Copy(res, cfg.DefaultLabels)
 	return res
 }
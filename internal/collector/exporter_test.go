@@ -2,13 +2,19 @@ package collector
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/mapping"
 	"github.com/jbub/pgbouncer_exporter/internal/sqlstore"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -26,7 +32,7 @@ func TestGetStoreResultExportEnabled(t *testing.T) {
 		ExportLists:     true,
 	}
 
-	exp := New(cfg, sqlstore.New(db))
+	exp := New(cfg, sqlstore.New(db, false, nil))
 	ctx := context.Background()
 
 	mock.ExpectQuery("SHOW STATS").WillReturnRows(sqlmock.NewRows(nil))
@@ -53,7 +59,7 @@ func TestGetStoreResultExportDisabled(t *testing.T) {
 		ExportLists:     false,
 	}
 
-	exp := New(cfg, sqlstore.New(db))
+	exp := New(cfg, sqlstore.New(db, false, nil))
 	ctx := context.Background()
 
 	_, err = exp.getStoreResult(ctx)
@@ -61,60 +67,156 @@ func TestGetStoreResultExportDisabled(t *testing.T) {
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestGetStoreResultTotalsError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	cfg := config.Config{
+		StoreTimeout: time.Second,
+		ExportPools:  true,
+		ExportTotals: true,
+	}
+
+	exp := New(cfg, sqlstore.New(db, false, nil))
+	ctx := context.Background()
+
+	mock.ExpectQuery("SHOW POOLS").WillReturnRows(sqlmock.NewRows([]string{"database", "user"}).AddRow("main", "app"))
+	mock.ExpectQuery("SHOW TOTALS").WillReturnError(errors.New("invalid command 'SHOW TOTALS', use SHOW HELP;"))
+
+	res, err := exp.getStoreResult(ctx)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, res.pools, 1)
+	require.Nil(t, res.totals)
+	require.Error(t, res.totalsErr)
+	require.True(t, exp.totalsFailing)
+
+	mock.ExpectQuery("SHOW POOLS").WillReturnRows(sqlmock.NewRows([]string{"database", "user"}).AddRow("main", "app"))
+	mock.ExpectQuery("SHOW TOTALS").WillReturnError(errors.New("invalid command 'SHOW TOTALS', use SHOW HELP;"))
+
+	expected := `
+# HELP pgbouncer_exporter_totals_error Whether the last SHOW TOTALS failed (1 for error, 0 for success).
+# TYPE pgbouncer_exporter_totals_error gauge
+pgbouncer_exporter_totals_error 1
+`
+	require.NoError(t, testutil.CollectAndCompare(exp, strings.NewReader(expected), "pgbouncer_exporter_totals_error"))
+
+	mock.ExpectQuery("SHOW POOLS").WillReturnRows(sqlmock.NewRows([]string{"database", "user"}).AddRow("main", "app"))
+	mock.ExpectQuery("SHOW TOTALS").WillReturnRows(sqlmock.NewRows([]string{"name", "value"}).AddRow("query_count", 10))
+
+	res, err = exp.getStoreResult(ctx)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.NotNil(t, res.totals)
+	require.NoError(t, res.totalsErr)
+	require.False(t, exp.totalsFailing)
+}
+
+func TestCollectUnknownColumns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	cfg := config.Config{
+		StoreTimeout: time.Second,
+		ExportLists:  true,
+	}
+
+	exp := New(cfg, sqlstore.New(db, false, nil))
+
+	for range 2 {
+		mock.ExpectQuery("SHOW LISTS").WillReturnRows(sqlmock.NewRows([]string{"list", "items", "extra"}).AddRow("pools", 1, 2))
+	}
+
+	expected := `
+# HELP pgbouncer_exporter_unknown_columns_total Number of times the admin console returned a column not known to the exporter, counted once per command result.
+# TYPE pgbouncer_exporter_unknown_columns_total counter
+pgbouncer_exporter_unknown_columns_total{column="extra",command="SHOW LISTS"} %v
+`
+	for i := 1; i <= 2; i++ {
+		err = testutil.CollectAndCompare(exp, strings.NewReader(fmt.Sprintf(expected, i)), "pgbouncer_exporter_unknown_columns_total")
+		require.NoError(t, err)
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBuildConstLabels(t *testing.T) {
+	cfg := config.Config{
+		Labels:        map[string]string{"env": "prod", "instance": "pg1"},
+		DefaultLabels: map[string]string{"env": "dev", "region": "eu"},
+	}
+
+	labels := buildConstLabels(cfg)
+	require.Equal(t, prometheus.Labels{"env": "dev", "instance": "pg1", "region": "eu"}, labels)
+}
+
 var (
-	parseLabelsCases = []struct {
-		name     string
-		value    string
-		expected prometheus.Labels
+	validateMetricsCases = []struct {
+		name string
+		cfg  config.Config
+		err  string
 	}{
 		{
-			name:     "empty",
-			value:    "",
-			expected: nil,
-		},
-		{
-			name:     "invalid item",
-			value:    "key",
-			expected: prometheus.Labels{},
-		},
-		{
-			name:  "blank item",
-			value: "key=",
-			expected: prometheus.Labels{
-				"key": "",
+			name: "mapping colliding with computed metric",
+			cfg: config.Config{
+				Mappings: []mapping.Mapping{{Command: "SHOW STATS", Column: "avg_query_time", Metric: "pgbouncer_exporter_stats_query_latency_seconds", Type: mapping.TypeGauge, Scale: 1, Labels: []string{"database"}}},
 			},
+			err: "metric pgbouncer_exporter_stats_query_latency_seconds collides with a built-in metric",
 		},
 		{
-			name:  "single item",
-			value: "key=value",
-			expected: prometheus.Labels{
-				"key": "value",
+			name: "mapping colliding with inventory metric",
+			cfg: config.Config{
+				Mappings: []mapping.Mapping{{Command: "SHOW LISTS", Column: "items", Metric: "pgbouncer_exporter_pools_count", Type: mapping.TypeGauge, Scale: 1}},
 			},
+			err: "metric pgbouncer_exporter_pools_count collides with a built-in metric",
 		},
 		{
-			name:  "multiple items",
-			value: "key=value key2=value2",
-			expected: prometheus.Labels{
-				"key":  "value",
-				"key2": "value2",
+			name: "custom query colliding with custom query error",
+			cfg: config.Config{
+				Queries: []mapping.Query{{
+					Command:  "SHOW MEM",
+					Mappings: []mapping.Mapping{{Command: "SHOW MEM", Column: "size", Metric: "pgbouncer_exporter_custom_query_error", Type: mapping.TypeGauge, Scale: 1, Labels: []string{"command"}}},
+				}},
 			},
+			err: "metric pgbouncer_exporter_custom_query_error collides with a built-in metric",
 		},
 		{
-			name:  "multiple items with space",
-			value: "key=value key2=value2 ",
-			expected: prometheus.Labels{
-				"key":  "value",
-				"key2": "value2",
+			name: "custom query colliding with start time",
+			cfg: config.Config{
+				Queries: []mapping.Query{{
+					Command:  "SHOW MEM",
+					Mappings: []mapping.Mapping{{Command: "SHOW MEM", Column: "size", Metric: "pgbouncer_exporter_start_time_seconds", Type: mapping.TypeGauge, Scale: 1}},
+				}},
 			},
+			err: "metric pgbouncer_exporter_start_time_seconds collides with a built-in metric",
 		},
 	}
 )
 
-func TestParseLabels(t *testing.T) {
-	for _, cs := range parseLabelsCases {
+func TestValidateConfigMetrics(t *testing.T) {
+	for _, cs := range validateMetricsCases {
 		t.Run(cs.name, func(t *testing.T) {
-			labels := parseLabels(cs.value)
-			require.Equal(t, cs.expected, labels)
+			require.EqualError(t, ValidateConfig(cs.cfg), cs.err)
 		})
 	}
 }
+
+func TestValidateConfig(t *testing.T) {
+	cfg := config.Config{
+		DefaultLabels: map[string]string{"env": "dev"},
+		Labels:        map[string]string{"instance": "pg1"},
+	}
+	require.NoError(t, ValidateConfig(cfg))
+
+	cfg.Labels = map[string]string{"database": "main"}
+	require.EqualError(t, ValidateConfig(cfg), `constant label "database" collides with the label of metric pgbouncer_exporter_stats_total_received`)
+
+	cfg.Labels = nil
+	cfg.Mappings = []mapping.Mapping{{Command: "SHOW LISTS", Column: "items", Metric: "pgbouncer_exporter_config_last_reload_successful", Help: "Items.", Type: mapping.TypeGauge, Scale: 1}}
+	require.ErrorContains(t, ValidateConfig(cfg), "could not register metrics: ")
+}
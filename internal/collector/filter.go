@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"slices"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+)
+
+var (
+	// databaseLabels are the names of labels holding the database name.
+	databaseLabels = []string{"database", "name"}
+
+	// userLabels are the names of labels holding the user name.
+	userLabels = []string{"user"}
+)
+
+type labelFilter struct {
+	index   int
+	filters []config.Filter
+}
+
+func (f labelFilter) match(labels []string) bool {
+	for _, filter := range f.filters {
+		if !filter.Match(labels[f.index]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m metric) matchLabels(labels []string) bool {
+	for _, f := range m.labelFilters {
+		if !f.match(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyFilters disables metrics filtered out by name and sets up the filters of label values,
+// both the global and the collector filters have to match.
+func applyFilters(metrics []metric, cfg config.Config) []metric {
+	for i := range metrics {
+		met := &metrics[i]
+		collectorFilters := cfg.CollectorFilters[met.collector]
+
+		if !cfg.Filters.Metrics.Match(met.name) || !collectorFilters.Metrics.Match(met.name) {
+			met.enabled = false
+			continue
+		}
+
+		met.labelFilters = appendLabelFilters(met.labelFilters, met.labels, databaseLabels, cfg.Filters.Databases, collectorFilters.Databases)
+		met.labelFilters = appendLabelFilters(met.labelFilters, met.labels, userLabels, cfg.Filters.Users, collectorFilters.Users)
+	}
+	return metrics
+}
+
+func appendLabelFilters(dst []labelFilter, labels []string, names []string, filters ...config.Filter) []labelFilter {
+	filters = slices.DeleteFunc(filters, config.Filter.IsEmpty)
+	if len(filters) == 0 {
+		return dst
+	}
+
+	for _, name := range names {
+		if idx := slices.Index(labels, name); idx >= 0 {
+			dst = append(dst, labelFilter{index: idx, filters: filters})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,137 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type testStore struct {
+	stats     []domain.Stat
+	pools     []domain.Pool
+	databases []domain.Database
+	lists     []domain.List
+	totals    *domain.Totals
+	queries   map[string][]domain.Row
+}
+
+func (s *testStore) GetStats(context.Context) ([]domain.Stat, error)         { return s.stats, nil }
+func (s *testStore) GetPools(context.Context) ([]domain.Pool, error)         { return s.pools, nil }
+func (s *testStore) GetDatabases(context.Context) ([]domain.Database, error) { return s.databases, nil }
+func (s *testStore) GetLists(context.Context) ([]domain.List, error)         { return s.lists, nil }
+func (s *testStore) GetTotals(context.Context) (*domain.Totals, error)       { return s.totals, nil }
+func (s *testStore) Check(context.Context) error                             { return nil }
+
+func (s *testStore) Query(_ context.Context, command string) ([]domain.Row, error) {
+	rows, ok := s.queries[command]
+	if !ok {
+		return nil, fmt.Errorf("invalid command '%v', use SHOW HELP;", command)
+	}
+	return rows, nil
+}
+
+func mustCompileFilter(include []string, exclude []string) config.Filter {
+	var f config.Filter
+	for _, expr := range include {
+		f.Include = append(f.Include, regexp.MustCompile("^(?:"+expr+")$"))
+	}
+	for _, expr := range exclude {
+		f.Exclude = append(f.Exclude, regexp.MustCompile("^(?:"+expr+")$"))
+	}
+	return f
+}
+
+func TestApplyFiltersMetricNames(t *testing.T) {
+	cfg := config.Config{
+		ExportStats: true,
+		ExportPools: true,
+		Filters: config.Filters{
+			Metrics: mustCompileFilter(nil, []string{"pgbouncer_exporter_stats_.*"}),
+		},
+		CollectorFilters: map[string]config.Filters{
+			config.CollectorPools: {
+				Metrics: mustCompileFilter([]string{".*_clients"}, nil),
+			},
+		},
+	}
+
+	for _, met := range applyFilters(buildMetrics(cfg), cfg) {
+		switch {
+		case strings.HasPrefix(met.name, "pgbouncer_exporter_stats_"):
+			require.False(t, met.enabled, met.name)
+		case strings.HasPrefix(met.name, "pgbouncer_exporter_pools_"):
+			require.Equal(t, strings.HasSuffix(met.name, "_clients"), met.enabled, met.name)
+		}
+	}
+}
+
+func TestCollectLabelFilters(t *testing.T) {
+	st := &testStore{
+		stats: []domain.Stat{
+			{Database: "main", TotalSent: 1},
+			{Database: "test_1", TotalSent: 2},
+		},
+		pools: []domain.Pool{
+			{Database: "main", User: "app", Active: 1},
+			{Database: "main", User: "admin", Active: 2},
+			{Database: "test_1", User: "app", Active: 3},
+		},
+		databases: []domain.Database{
+			{Name: "main", PoolSize: 1},
+			{Name: "test_1", PoolSize: 2},
+		},
+	}
+
+	cfg := config.Config{
+		StoreTimeout:    time.Second,
+		ExportStats:     true,
+		ExportPools:     true,
+		ExportDatabases: true,
+		Filters: config.Filters{
+			Users: mustCompileFilter(nil, []string{"admin"}),
+		},
+		CollectorFilters: map[string]config.Filters{
+			config.CollectorPools: {
+				Databases: mustCompileFilter(nil, []string{"test_.*"}),
+			},
+			config.CollectorDatabases: {
+				Databases: mustCompileFilter([]string{"main"}, nil),
+			},
+		},
+	}
+
+	exp := New(cfg, st)
+
+	expected := `
+# HELP pgbouncer_exporter_database_pool_size Maximum number of server connections.
+# TYPE pgbouncer_exporter_database_pool_size gauge
+pgbouncer_exporter_database_pool_size{name="main",pool_mode=""} 1
+# HELP pgbouncer_exporter_pools_active_clients Client connections that are linked to server connection and can process queries.
+# TYPE pgbouncer_exporter_pools_active_clients gauge
+pgbouncer_exporter_pools_active_clients{database="main",pool_mode="",user="app"} 1
+# HELP pgbouncer_exporter_stats_total_sent Total volume in bytes of network traffic sent by pgbouncer.
+# TYPE pgbouncer_exporter_stats_total_sent gauge
+pgbouncer_exporter_stats_total_sent{database="main"} 1
+pgbouncer_exporter_stats_total_sent{database="test_1"} 2
+`
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(exp)
+
+	err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"pgbouncer_exporter_database_pool_size",
+		"pgbouncer_exporter_pools_active_clients",
+		"pgbouncer_exporter_stats_total_sent",
+	)
+	require.NoError(t, err)
+}
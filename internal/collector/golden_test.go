@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+	"github.com/jbub/pgbouncer_exporter/internal/pgbouncertest"
+	"github.com/jbub/pgbouncer_exporter/internal/sqlstore"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+type closingStore interface {
+	domain.Store
+	Close() error
+}
+
+var (
+	goldenStores = []struct {
+		name string
+		open func(t *testing.T, url string, strict bool) closingStore
+	}{
+		{
+			name: config.StoreSQL,
+			open: func(t *testing.T, url string, strict bool) closingStore {
+				db, err := sqlstore.Open(url)
+				require.NoError(t, err)
+				return sqlstore.New(db, strict, nil)
+			},
+		},
+		{
+			name: config.StorePgx,
+			open: func(t *testing.T, url string, strict bool) closingStore {
+				return sqlstore.NewPgx(func() (string, error) { return url, nil }, strict, nil)
+			},
+		},
+	}
+)
+
+// TestGolden compares the metrics exported for the recorded admin console output
+// of each pgbouncer version with the golden files, run with -update to regenerate them.
+func TestGolden(t *testing.T) {
+	cfg := config.Config{
+		StoreTimeout:    time.Second,
+		ExportStats:     true,
+		ExportPools:     true,
+		ExportDatabases: true,
+		ExportLists:     true,
+		ExportTotals:    true,
+	}
+
+	for _, version := range pgbouncertest.Versions() {
+		t.Run(version, func(t *testing.T) {
+			fixture, err := pgbouncertest.LoadFixture(version)
+			require.NoError(t, err)
+
+			srv := pgbouncertest.NewServer(fixture)
+			defer srv.Close() //nolint:errcheck
+
+			path := filepath.Join("testdata", "golden", version+".prom")
+
+			for _, gs := range goldenStores {
+				t.Run(gs.name, func(t *testing.T) {
+					store := gs.open(t, srv.URL(), true)
+					defer store.Close() //nolint:errcheck
+
+					require.NoError(t, store.Check(context.Background()))
+
+					reg := prometheus.NewPedanticRegistry()
+					reg.MustRegister(New(cfg, store))
+
+					actual := gatherText(t, reg)
+
+					if *update {
+						require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+						require.NoError(t, os.WriteFile(path, actual, 0o644))
+					}
+
+					expected, err := os.ReadFile(path)
+					require.NoError(t, err)
+					require.Equal(t, string(expected), string(actual))
+				})
+			}
+		})
+	}
+}
+
+// lenientVersion is the pgbouncer version whose fixture is extended with the columns
+// not known to the exporter in TestGoldenLenient.
+const lenientVersion = "1.25"
+
+// TestGoldenLenient compares the metrics exported by the lenient stores for the admin console output
+// with columns not known to the exporter, as returned by a newer pgbouncer version, with the golden file.
+func TestGoldenLenient(t *testing.T) {
+	cfg := config.Config{
+		StoreTimeout:    time.Second,
+		ExportStats:     true,
+		ExportPools:     true,
+		ExportDatabases: true,
+		ExportLists:     true,
+		ExportTotals:    true,
+	}
+
+	fixture, err := pgbouncertest.LoadFixture(lenientVersion)
+	require.NoError(t, err)
+
+	addColumn(fixture, "SHOW STATS", pgbouncertest.Column{Name: "total_future_count", Type: pgbouncertest.TypeNumeric}, "12")
+	addColumn(fixture, "SHOW POOLS", pgbouncertest.Column{Name: "sv_future", Type: pgbouncertest.TypeInt4}, "3")
+	addColumn(fixture, "SHOW DATABASES", pgbouncertest.Column{Name: "future_mode", Type: pgbouncertest.TypeText}, "auto")
+	addColumn(fixture, "SHOW LISTS", pgbouncertest.Column{Name: "future_items", Type: pgbouncertest.TypeInt4}, "0")
+
+	srv := pgbouncertest.NewServer(fixture)
+	defer srv.Close() //nolint:errcheck
+
+	path := filepath.Join("testdata", "golden", lenientVersion+"-lenient.prom")
+
+	for _, gs := range goldenStores {
+		t.Run(gs.name, func(t *testing.T) {
+			store := gs.open(t, srv.URL(), false)
+			defer store.Close() //nolint:errcheck
+
+			require.NoError(t, store.Check(context.Background()))
+
+			reg := prometheus.NewPedanticRegistry()
+			reg.MustRegister(New(cfg, store))
+
+			actual := gatherText(t, reg)
+
+			if *update {
+				require.NoError(t, os.WriteFile(path, actual, 0o644))
+			}
+
+			expected, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.Equal(t, string(expected), string(actual))
+		})
+	}
+}
+
+// addColumn appends the column with the given value in all rows to the result of the command.
+func addColumn(fixture *pgbouncertest.Fixture, command string, col pgbouncertest.Column, value string) {
+	res := fixture.Results[command]
+	res.Columns = append(res.Columns, col)
+	for i := range res.Rows {
+		res.Rows[i] = append(res.Rows[i], &value)
+	}
+	fixture.Results[command] = res
+}
+
+func gatherText(t *testing.T, reg prometheus.Gatherer) []byte {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, mf := range families {
+		require.NoError(t, enc.Encode(mf))
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"log"
+	"sync"
+
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+)
+
+// inventory keeps the databases and pools seen by the previous scrape to count the ones added
+// and removed since the exporter started, it is shared by the Exporters created using Exporter.Select.
+type inventory struct {
+	mut       sync.Mutex
+	databases keySet[string]
+	pools     keySet[poolKey]
+}
+
+// inventoryCounts represents the result of observing a set of databases or pools.
+type inventoryCounts struct {
+	count   int
+	added   int64
+	removed int64
+}
+
+type poolKey struct {
+	database string
+	user     string
+}
+
+func (k poolKey) String() string {
+	return k.database + "/" + k.user
+}
+
+// observeDatabases stores the databases as the latest set and logs the changes since the previous one.
+func (inv *inventory) observeDatabases(databases []domain.Database) inventoryCounts {
+	keys := make([]string, 0, len(databases))
+	for _, database := range databases {
+		keys = append(keys, database.Name)
+	}
+
+	inv.mut.Lock()
+	defer inv.mut.Unlock()
+
+	return inv.databases.observe(keys, func(name string, added bool) {
+		if added {
+			log.Printf("Database %v was added", name)
+		} else {
+			log.Printf("Database %v was removed", name)
+		}
+	})
+}
+
+// observePools stores the pools as the latest set and logs the changes since the previous one.
+func (inv *inventory) observePools(pools []domain.Pool) inventoryCounts {
+	keys := make([]poolKey, 0, len(pools))
+	for _, pool := range pools {
+		keys = append(keys, poolKey{database: pool.Database, user: pool.User})
+	}
+
+	inv.mut.Lock()
+	defer inv.mut.Unlock()
+
+	return inv.pools.observe(keys, func(key poolKey, added bool) {
+		if added {
+			log.Printf("Pool %v was added", key)
+		} else {
+			log.Printf("Pool %v was removed", key)
+		}
+	})
+}
+
+// keySet is a set of keys which counts the keys added and removed between observations,
+// the first observation is the baseline and is not counted as added.
+type keySet[K comparable] struct {
+	known   map[K]struct{}
+	added   int64
+	removed int64
+}
+
+func (s *keySet[K]) observe(keys []K, changed func(key K, added bool)) inventoryCounts {
+	next := make(map[K]struct{}, len(keys))
+	for _, key := range keys {
+		next[key] = struct{}{}
+	}
+
+	if s.known != nil {
+		for _, key := range keys {
+			if _, ok := s.known[key]; !ok {
+				s.known[key] = struct{}{}
+				s.added++
+				changed(key, true)
+			}
+		}
+		for key := range s.known {
+			if _, ok := next[key]; !ok {
+				s.removed++
+				changed(key, false)
+			}
+		}
+	}
+	s.known = next
+
+	return inventoryCounts{
+		count:   len(next),
+		added:   s.added,
+		removed: s.removed,
+	}
+}
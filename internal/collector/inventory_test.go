@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	keySetCases = []struct {
+		name     string
+		scrapes  [][]string
+		expected inventoryCounts
+		changes  []string
+	}{
+		{
+			name:     "first scrape",
+			scrapes:  [][]string{{"a", "b"}},
+			expected: inventoryCounts{count: 2},
+		},
+		{
+			name:     "unchanged",
+			scrapes:  [][]string{{"a", "b"}, {"b", "a"}},
+			expected: inventoryCounts{count: 2},
+		},
+		{
+			name:     "added and removed",
+			scrapes:  [][]string{{"a", "b"}, {"a", "c"}, {"a", "c", "d"}},
+			expected: inventoryCounts{count: 3, added: 2, removed: 1},
+			changes:  []string{"+c", "-b", "+d"},
+		},
+		{
+			name:     "removed and added back",
+			scrapes:  [][]string{{"a", "b"}, {"a"}, {"a", "b"}},
+			expected: inventoryCounts{count: 2, added: 1, removed: 1},
+			changes:  []string{"-b", "+b"},
+		},
+		{
+			name:     "duplicate keys",
+			scrapes:  [][]string{{}, {"a", "a"}},
+			expected: inventoryCounts{count: 1, added: 1},
+			changes:  []string{"+a"},
+		},
+	}
+)
+
+func TestKeySetObserve(t *testing.T) {
+	for _, cs := range keySetCases {
+		t.Run(cs.name, func(t *testing.T) {
+			var (
+				set     keySet[string]
+				counts  inventoryCounts
+				changes []string
+			)
+			for _, keys := range cs.scrapes {
+				counts = set.observe(keys, func(key string, added bool) {
+					if added {
+						changes = append(changes, "+"+key)
+					} else {
+						changes = append(changes, "-"+key)
+					}
+				})
+			}
+			require.Equal(t, cs.expected, counts)
+			require.Equal(t, cs.changes, changes)
+		})
+	}
+}
+
+func TestCollectInventory(t *testing.T) {
+	st := &testStore{
+		databases: []domain.Database{{Name: "main"}, {Name: "old"}},
+		pools:     []domain.Pool{{Database: "main", User: "app"}, {Database: "old", User: "app"}},
+	}
+
+	cfg := config.Config{
+		StoreTimeout:    time.Second,
+		ExportPools:     true,
+		ExportDatabases: true,
+	}
+
+	exp := New(cfg, st)
+	names := []string{
+		"pgbouncer_exporter_database_count",
+		"pgbouncer_exporter_database_added_total",
+		"pgbouncer_exporter_database_removed_total",
+		"pgbouncer_exporter_pools_count",
+		"pgbouncer_exporter_pools_added_total",
+		"pgbouncer_exporter_pools_removed_total",
+	}
+
+	require.NoError(t, testutil.CollectAndCompare(exp, strings.NewReader(`
+# HELP pgbouncer_exporter_database_added_total Number of databases added to pgbouncer since the exporter started.
+# TYPE pgbouncer_exporter_database_added_total counter
+pgbouncer_exporter_database_added_total 0
+# HELP pgbouncer_exporter_database_count Number of databases configured in pgbouncer.
+# TYPE pgbouncer_exporter_database_count gauge
+pgbouncer_exporter_database_count 2
+# HELP pgbouncer_exporter_database_removed_total Number of databases removed from pgbouncer since the exporter started.
+# TYPE pgbouncer_exporter_database_removed_total counter
+pgbouncer_exporter_database_removed_total 0
+# HELP pgbouncer_exporter_pools_added_total Number of pools added to pgbouncer since the exporter started.
+# TYPE pgbouncer_exporter_pools_added_total counter
+pgbouncer_exporter_pools_added_total 0
+# HELP pgbouncer_exporter_pools_count Number of pools in pgbouncer.
+# TYPE pgbouncer_exporter_pools_count gauge
+pgbouncer_exporter_pools_count 2
+# HELP pgbouncer_exporter_pools_removed_total Number of pools removed from pgbouncer since the exporter started.
+# TYPE pgbouncer_exporter_pools_removed_total counter
+pgbouncer_exporter_pools_removed_total 0
+`), names...))
+
+	// the old database is removed and a new one added by RELOAD
+	st.databases = []domain.Database{{Name: "main"}, {Name: "new"}, {Name: "other"}}
+	st.pools = []domain.Pool{{Database: "main", User: "app"}, {Database: "new", User: "app"}}
+
+	// the inventory is shared with the selected Exporters
+	pools, err := exp.Select([]string{config.CollectorPools})
+	require.NoError(t, err)
+	require.NoError(t, testutil.CollectAndCompare(pools, strings.NewReader(`
+# HELP pgbouncer_exporter_pools_added_total Number of pools added to pgbouncer since the exporter started.
+# TYPE pgbouncer_exporter_pools_added_total counter
+pgbouncer_exporter_pools_added_total 1
+# HELP pgbouncer_exporter_pools_count Number of pools in pgbouncer.
+# TYPE pgbouncer_exporter_pools_count gauge
+pgbouncer_exporter_pools_count 2
+# HELP pgbouncer_exporter_pools_removed_total Number of pools removed from pgbouncer since the exporter started.
+# TYPE pgbouncer_exporter_pools_removed_total counter
+pgbouncer_exporter_pools_removed_total 1
+`), names...))
+
+	require.NoError(t, testutil.CollectAndCompare(exp, strings.NewReader(`
+# HELP pgbouncer_exporter_database_added_total Number of databases added to pgbouncer since the exporter started.
+# TYPE pgbouncer_exporter_database_added_total counter
+pgbouncer_exporter_database_added_total 2
+# HELP pgbouncer_exporter_database_count Number of databases configured in pgbouncer.
+# TYPE pgbouncer_exporter_database_count gauge
+pgbouncer_exporter_database_count 3
+# HELP pgbouncer_exporter_database_removed_total Number of databases removed from pgbouncer since the exporter started.
+# TYPE pgbouncer_exporter_database_removed_total counter
+pgbouncer_exporter_database_removed_total 1
+# HELP pgbouncer_exporter_pools_added_total Number of pools added to pgbouncer since the exporter started.
+# TYPE pgbouncer_exporter_pools_added_total counter
+pgbouncer_exporter_pools_added_total 1
+# HELP pgbouncer_exporter_pools_count Number of pools in pgbouncer.
+# TYPE pgbouncer_exporter_pools_count gauge
+pgbouncer_exporter_pools_count 2
+# HELP pgbouncer_exporter_pools_removed_total Number of pools removed from pgbouncer since the exporter started.
+# TYPE pgbouncer_exporter_pools_removed_total counter
+pgbouncer_exporter_pools_removed_total 1
+`), names...))
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+)
+
+// statsHistory keeps the stats of the previous scrape, it is shared by the Exporters
+// created using Exporter.Select so that deltas span the time since any previous scrape.
+type statsHistory struct {
+	mut       sync.Mutex
+	prev      map[string]domain.Stat
+	startTime time.Time
+	restarts  int64
+}
+
+// statsSnapshot represents the result of statsHistory.observe.
+type statsSnapshot struct {
+	prevStats map[string]domain.Stat
+	startTime time.Time
+	restarts  int64
+}
+
+// observe stores stats as the latest snapshot and returns the previous one keyed by database
+// along with the restart tracking state updated using the new stats.
+func (h *statsHistory) observe(stats []domain.Stat, now time.Time) statsSnapshot {
+	next := make(map[string]domain.Stat, len(stats))
+	for _, stat := range stats {
+		next[stat.Database] = stat
+	}
+
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	prev := h.prev
+	h.prev = next
+
+	// the start time of pgbouncer is unknown until a restart is detected
+	if detectRestart(prev, stats) {
+		h.startTime = now
+		h.restarts++
+	}
+
+	return statsSnapshot{
+		prevStats: prev,
+		startTime: h.startTime,
+		restarts:  h.restarts,
+	}
+}
+
+// latency returns the average duration in seconds of a single event between two snapshots of
+// a total time counter in microseconds and a total count counter. When any of the counters decreased
+// pgbouncer was restarted and the current values are used as deltas. The result is not ok when
+// there were no events.
+func latency(prevTime int64, prevCount int64, curTime int64, curCount int64) (float64, bool) {
+	if curTime < prevTime || curCount < prevCount {
+		prevTime, prevCount = 0, 0
+	}
+
+	count := curCount - prevCount
+	if count <= 0 {
+		return 0, false
+	}
+	return float64(curTime-prevTime) / float64(count) / 1e6, true
+}
+
+// statLatencies returns the latencies computed using the given counters for stats which
+// have a snapshot from the previous scrape.
+func statLatencies(res *storeResult, totalTime func(domain.Stat) int64, totalCount func(domain.Stat) int64) (results []metricResult) {
+	for _, stat := range res.stats {
+		prev, ok := res.prevStats[stat.Database]
+		if !ok {
+			continue
+		}
+		if value, ok := latency(totalTime(prev), totalCount(prev), totalTime(stat), totalCount(stat)); ok {
+			results = append(results, metricResult{
+				labels: []string{stat.Database},
+				value:  value,
+			})
+		}
+	}
+	return results
+}
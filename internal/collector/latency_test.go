@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	latencyCases = []struct {
+		name      string
+		prevTime  int64
+		prevCount int64
+		curTime   int64
+		curCount  int64
+		expected  float64
+		ok        bool
+	}{
+		{
+			name:      "increase",
+			prevTime:  1_000_000,
+			prevCount: 10,
+			curTime:   3_000_000,
+			curCount:  20,
+			expected:  0.2,
+			ok:        true,
+		},
+		{
+			name:      "no events",
+			prevTime:  1_000_000,
+			prevCount: 10,
+			curTime:   1_000_000,
+			curCount:  10,
+			ok:        false,
+		},
+		{
+			name:      "reset of both counters",
+			prevTime:  5_000_000,
+			prevCount: 50,
+			curTime:   400_000,
+			curCount:  4,
+			expected:  0.1,
+			ok:        true,
+		},
+		{
+			name:      "reset of count only",
+			prevTime:  5_000_000,
+			prevCount: 50,
+			curTime:   6_000_000,
+			curCount:  10,
+			expected:  0.6,
+			ok:        true,
+		},
+		{
+			name:      "reset without events",
+			prevTime:  5_000_000,
+			prevCount: 50,
+			curTime:   0,
+			curCount:  0,
+			ok:        false,
+		},
+	}
+)
+
+func TestLatency(t *testing.T) {
+	for _, cs := range latencyCases {
+		t.Run(cs.name, func(t *testing.T) {
+			value, ok := latency(cs.prevTime, cs.prevCount, cs.curTime, cs.curCount)
+			require.Equal(t, cs.ok, ok)
+			require.InDelta(t, cs.expected, value, 1e-9)
+		})
+	}
+}
+
+func TestCollectLatency(t *testing.T) {
+	st := &testStore{
+		stats: []domain.Stat{
+			{Database: "main", TotalQueryTime: 1_000_000, TotalQueryCount: 100, TotalXactTime: 2_000_000, TotalXactCount: 10},
+		},
+	}
+
+	cfg := config.Config{
+		StoreTimeout: time.Second,
+		ExportStats:  true,
+	}
+
+	exp := New(cfg, st)
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(exp)
+
+	names := []string{
+		"pgbouncer_exporter_stats_query_latency_seconds",
+		"pgbouncer_exporter_stats_xact_latency_seconds",
+	}
+
+	// first scrape has no previous snapshot
+	count, err := testutil.GatherAndCount(reg, names...)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	st.stats = []domain.Stat{
+		{Database: "main", TotalQueryTime: 1_500_000, TotalQueryCount: 200, TotalXactTime: 3_000_000, TotalXactCount: 20},
+		{Database: "new", TotalQueryTime: 1_000, TotalQueryCount: 1},
+	}
+
+	expected := `
+# HELP pgbouncer_exporter_stats_query_latency_seconds Average query duration in seconds since the previous scrape, computed from the total_query_time and total_query_count deltas.
+# TYPE pgbouncer_exporter_stats_query_latency_seconds gauge
+pgbouncer_exporter_stats_query_latency_seconds{database="main"} 0.005
+# HELP pgbouncer_exporter_stats_xact_latency_seconds Average transaction duration in seconds since the previous scrape, computed from the total_xact_time and total_xact_count deltas.
+# TYPE pgbouncer_exporter_stats_xact_latency_seconds gauge
+pgbouncer_exporter_stats_xact_latency_seconds{database="main"} 0.1
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), names...))
+
+	// selected exporters share the history
+	selected, err := exp.Select([]string{config.CollectorStats})
+	require.NoError(t, err)
+
+	st.stats = []domain.Stat{
+		{Database: "main", TotalQueryTime: 100_000, TotalQueryCount: 50, TotalXactTime: 3_000_000, TotalXactCount: 20},
+	}
+
+	selectedReg := prometheus.NewPedanticRegistry()
+	selectedReg.MustRegister(selected)
+
+	expected = `
+# HELP pgbouncer_exporter_stats_query_latency_seconds Average query duration in seconds since the previous scrape, computed from the total_query_time and total_query_count deltas.
+# TYPE pgbouncer_exporter_stats_query_latency_seconds gauge
+pgbouncer_exporter_stats_query_latency_seconds{database="main"} 0.002
+`
+	require.NoError(t, testutil.GatherAndCompare(selectedReg, strings.NewReader(expected), names...))
+}
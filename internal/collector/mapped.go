@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+	"github.com/jbub/pgbouncer_exporter/internal/mapping"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// commandCollectors maps the admin console commands to the collectors exporting their rows.
+var commandCollectors = map[string]string{
+	domain.CommandStats:     config.CollectorStats,
+	domain.CommandPools:     config.CollectorPools,
+	domain.CommandDatabases: config.CollectorDatabases,
+	domain.CommandLists:     config.CollectorLists,
+	domain.CommandTotals:    config.CollectorTotals,
+}
+
+var mappingValueTypes = map[string]prometheus.ValueType{
+	mapping.TypeGauge:   prometheus.GaugeValue,
+	mapping.TypeCounter: prometheus.CounterValue,
+}
+
+// mappings returns the built-in mappings followed by the mappings and the custom queries from the config.
+func mappings(cfg config.Config) []mapping.Mapping {
+	res := append(mapping.Default(), cfg.Mappings...)
+	return append(res, mapping.QueryMappings(cfg.Queries)...)
+}
+
+// mappedMetrics returns the metrics defined by the mappings, mappings sharing
+// the metric name are merged into a single metric.
+func mappedMetrics(cfg config.Config) []metric {
+	enabled := map[string]bool{
+		domain.CommandStats:     cfg.ExportStats,
+		domain.CommandPools:     cfg.ExportPools,
+		domain.CommandDatabases: cfg.ExportDatabases,
+		domain.CommandLists:     cfg.ExportLists,
+		domain.CommandTotals:    cfg.ExportTotals,
+	}
+	for _, q := range cfg.Queries {
+		enabled[q.Command] = true
+	}
+
+	var groups [][]mapping.Mapping
+	index := make(map[string]int)
+
+	for _, m := range mappings(cfg) {
+		if i, ok := index[m.Metric]; ok {
+			groups[i] = append(groups[i], m)
+			continue
+		}
+		index[m.Metric] = len(groups)
+		groups = append(groups, []mapping.Mapping{m})
+	}
+
+	metrics := make([]metric, 0, len(groups))
+	for _, group := range groups {
+		m := group[0]
+		metrics = append(metrics, metric{
+			enabled:   enabled[m.Command],
+			collector: commandCollectors[m.Command],
+			name:      m.Metric,
+			help:      m.Help,
+			labels:    m.Labels,
+			valType:   mappingValueTypes[m.Type],
+			eval: func(res *storeResult) (results []metricResult) {
+				for _, rec := range res.records(m.Command) {
+					value, ok := mappedValue(rec, group)
+					if !ok {
+						continue
+					}
+					results = append(results, metricResult{
+						labels: recordLabels(rec, m.Labels),
+						value:  value,
+					})
+				}
+				return results
+			},
+		})
+	}
+	return metrics
+}
+
+// mappedValue returns the scaled value of the first column of the mappings present in the record.
+func mappedValue(rec domain.Record, group []mapping.Mapping) (float64, bool) {
+	for _, m := range group {
+		if value, ok := rec.Value(m.Column); ok {
+			return value * m.Scale, true
+		}
+	}
+	return 0, false
+}
+
+func recordLabels(rec domain.Record, columns []string) []string {
+	labels := make([]string, 0, len(columns))
+	for _, column := range columns {
+		labels = append(labels, rec.Label(column))
+	}
+	return labels
+}
+
+// records returns the rows returned by the command.
+func (res *storeResult) records(command string) []domain.Record {
+	switch command {
+	case domain.CommandStats:
+		return toRecords(res.stats)
+	case domain.CommandPools:
+		return toRecords(res.pools)
+	case domain.CommandDatabases:
+		return toRecords(res.databases)
+	case domain.CommandLists:
+		return toRecords(res.lists)
+	case domain.CommandTotals:
+		if res.totals == nil {
+			return nil
+		}
+		return toRecords([]domain.Totals{*res.totals})
+	}
+	for _, q := range res.queries {
+		if q.command == command {
+			return toRecords(q.rows)
+		}
+	}
+	return nil
+}
+
+func toRecords[T domain.Record](rows []T) []domain.Record {
+	records := make([]domain.Record, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, row)
+	}
+	return records
+}
@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+	"github.com/jbub/pgbouncer_exporter/internal/mapping"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectMappedMetrics(t *testing.T) {
+	st := &testStore{
+		pools: []domain.Pool{
+			{
+				Database:  "main",
+				User:      "app",
+				MaxWaitUs: 1500000,
+				Extra: domain.Row{
+					Labels: map[string]string{"sv_state": "busy"},
+					Values: domain.Values{"sv_new": 2, "sv_other": 3},
+				},
+			},
+			{
+				Database: "main",
+				User:     "admin",
+				Extra:    domain.Row{Values: domain.Values{"sv_renamed": 4}},
+			},
+		},
+	}
+
+	mappings, err := mapping.Parse([]byte(`
+mappings:
+  - command: SHOW POOLS
+    column: sv_new
+    metric: pgbouncer_exporter_pools_new_server
+    help: New server connections.
+    labels: [database, user, sv_state]
+  - command: SHOW POOLS
+    column: sv_renamed
+    metric: pgbouncer_exporter_pools_new_server
+    help: New server connections.
+    labels: [database, user, sv_state]
+  - command: SHOW POOLS
+    column: maxwait_us
+    metric: pgbouncer_exporter_pools_max_wait_seconds_total
+    help: Longest waiting time in seconds.
+    type: counter
+    scale: 0.000001
+    labels: [user]
+`))
+	require.NoError(t, err)
+
+	cfg := config.Config{
+		StoreTimeout: time.Second,
+		ExportPools:  true,
+		Mappings:     mappings,
+	}
+
+	exp := New(cfg, st)
+
+	expected := `
+# HELP pgbouncer_exporter_pools_max_wait_seconds_total Longest waiting time in seconds.
+# TYPE pgbouncer_exporter_pools_max_wait_seconds_total counter
+pgbouncer_exporter_pools_max_wait_seconds_total{user="admin"} 0
+pgbouncer_exporter_pools_max_wait_seconds_total{user="app"} 1.5
+# HELP pgbouncer_exporter_pools_new_server New server connections.
+# TYPE pgbouncer_exporter_pools_new_server gauge
+pgbouncer_exporter_pools_new_server{database="main",sv_state="",user="admin"} 4
+pgbouncer_exporter_pools_new_server{database="main",sv_state="busy",user="app"} 2
+# HELP pgbouncer_exporter_pools_sv_other Value of the sv_other column returned by SHOW POOLS, the column is not known to the exporter.
+# TYPE pgbouncer_exporter_pools_sv_other gauge
+pgbouncer_exporter_pools_sv_other{database="main",pool_mode="",user="app"} 3
+`
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(exp)
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"pgbouncer_exporter_pools_max_wait_seconds_total",
+		"pgbouncer_exporter_pools_new_server",
+		"pgbouncer_exporter_pools_sv_new",
+		"pgbouncer_exporter_pools_sv_renamed",
+		"pgbouncer_exporter_pools_sv_other",
+	)
+	require.NoError(t, err)
+}
+
+func TestCollectCustomQueries(t *testing.T) {
+	st := &testStore{
+		queries: map[string][]domain.Row{
+			"SHOW STATS_TOTALS": {
+				{
+					Labels: map[string]string{"database": "main"},
+					Values: domain.Values{"total_xact_count": 10, "total_wait_time": 2500000},
+				},
+			},
+		},
+	}
+
+	queries, err := mapping.ParseQueries([]byte(`
+queries:
+  - command: SHOW STATS_TOTALS
+    metrics:
+      - column: total_xact_count
+        metric: pgbouncer_stats_totals_xact_count
+        help: Total number of transactions.
+        type: counter
+        labels: [database]
+      - column: total_wait_time
+        metric: pgbouncer_stats_totals_wait_seconds
+        help: Total wait time in seconds.
+        scale: 0.000001
+        labels: [database]
+  - command: SHOW MEM
+    metrics:
+      - column: used
+        metric: pgbouncer_mem_used
+`))
+	require.NoError(t, err)
+
+	cfg := config.Config{
+		StoreTimeout: time.Second,
+		ExportStats:  true,
+		Queries:      queries,
+	}
+
+	exp := New(cfg, st)
+
+	expected := `
+# HELP pgbouncer_exporter_custom_query_error Whether the last run of the custom query failed (1 for error, 0 for success).
+# TYPE pgbouncer_exporter_custom_query_error gauge
+pgbouncer_exporter_custom_query_error{command="SHOW MEM"} 1
+pgbouncer_exporter_custom_query_error{command="SHOW STATS_TOTALS"} 0
+# HELP pgbouncer_stats_totals_wait_seconds Total wait time in seconds.
+# TYPE pgbouncer_stats_totals_wait_seconds gauge
+pgbouncer_stats_totals_wait_seconds{database="main"} 2.5
+# HELP pgbouncer_stats_totals_xact_count Total number of transactions.
+# TYPE pgbouncer_stats_totals_xact_count counter
+pgbouncer_stats_totals_xact_count{database="main"} 10
+`
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(exp)
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"pgbouncer_exporter_custom_query_error",
+		"pgbouncer_stats_totals_wait_seconds",
+		"pgbouncer_stats_totals_xact_count",
+		"pgbouncer_mem_used",
+	)
+	require.NoError(t, err)
+
+	// the custom queries are only run when selected
+	stats, err := exp.Select([]string{config.CollectorStats})
+	require.NoError(t, err)
+	require.Zero(t, testutil.CollectAndCount(stats, "pgbouncer_stats_totals_xact_count"))
+
+	selected, err := exp.Select([]string{config.CollectorQueries})
+	require.NoError(t, err)
+	require.Equal(t, 1, testutil.CollectAndCount(selected, "pgbouncer_stats_totals_xact_count"))
+
+	_, err = New(config.Config{}, st).Select([]string{config.CollectorQueries})
+	require.EqualError(t, err, `collector "queries" is disabled`)
+}
@@ -2,317 +2,259 @@ package collector
 
 import (
 	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// buildMetrics returns the metrics defined by the mappings followed by the metrics computed from the store result.
 func buildMetrics(cfg config.Config) []metric {
-	return []metric{
+	return append(mappedMetrics(cfg), []metric{
 		{
-			enabled: cfg.ExportStats,
-			name:    fqName(SubsystemStats, "total_received"),
-			help:    "Total volume in bytes of network traffic received by pgbouncer.",
-			labels:  []string{"database"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, stat := range res.stats {
-					results = append(results, metricResult{
-						labels: []string{stat.Database},
-						value:  float64(stat.TotalReceived),
-					})
-				}
-				return results
+			enabled:   cfg.ExportStats,
+			collector: config.CollectorStats,
+			name:      fqName(SubsystemStats, "query_latency_seconds"),
+			help:      "Average query duration in seconds since the previous scrape, computed from the total_query_time and total_query_count deltas.",
+			labels:    []string{"database"},
+			valType:   prometheus.GaugeValue,
+			eval: func(res *storeResult) []metricResult {
+				return statLatencies(res,
+					func(stat domain.Stat) int64 { return stat.TotalQueryTime },
+					func(stat domain.Stat) int64 { return stat.TotalQueryCount },
+				)
 			},
 		},
 		{
-			enabled: cfg.ExportStats,
-			name:    fqName(SubsystemStats, "total_sent"),
-			help:    "Total volume in bytes of network traffic sent by pgbouncer.",
-			labels:  []string{"database"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, stat := range res.stats {
-					results = append(results, metricResult{
-						labels: []string{stat.Database},
-						value:  float64(stat.TotalSent),
-					})
-				}
-				return results
+			enabled:   cfg.ExportStats,
+			collector: config.CollectorStats,
+			name:      fqName(SubsystemStats, "xact_latency_seconds"),
+			help:      "Average transaction duration in seconds since the previous scrape, computed from the total_xact_time and total_xact_count deltas.",
+			labels:    []string{"database"},
+			valType:   prometheus.GaugeValue,
+			eval: func(res *storeResult) []metricResult {
+				return statLatencies(res,
+					func(stat domain.Stat) int64 { return stat.TotalXactTime },
+					func(stat domain.Stat) int64 { return stat.TotalXactCount },
+				)
 			},
 		},
 		{
-			enabled: cfg.ExportStats,
-			name:    fqName(SubsystemStats, "total_query_time"),
-			help:    "Total number of microseconds spent by pgbouncer when actively connected to PostgreSQL.",
-			labels:  []string{"database"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, stat := range res.stats {
-					results = append(results, metricResult{
-						labels: []string{stat.Database},
-						value:  float64(stat.TotalQueryTime),
-					})
+			enabled:   cfg.ExportStats,
+			collector: config.CollectorStats,
+			name:      fqName("", "start_time_seconds"),
+			help:      "Approximate start time of pgbouncer in unix seconds, the time the last restart was detected, not exported until a restart is detected.",
+			valType:   prometheus.GaugeValue,
+			eval: func(res *storeResult) []metricResult {
+				if res.startTime.IsZero() {
+					return nil
 				}
-				return results
-			},
-		},
-		{
-			enabled: cfg.ExportStats,
-			name:    fqName(SubsystemStats, "total_xact_time"),
-			help:    "Total number of microseconds spent by pgbouncer when connected to PostgreSQL in a transaction, either idle in transaction or executing queries.",
-			labels:  []string{"database"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, stat := range res.stats {
-					results = append(results, metricResult{
-						labels: []string{stat.Database},
-						value:  float64(stat.TotalXactTime),
-					})
+				return []metricResult{
+					{value: float64(res.startTime.UnixNano()) / 1e9},
 				}
-				return results
 			},
 		},
 		{
-			enabled: cfg.ExportStats,
-			name:    fqName(SubsystemStats, "total_query_count"),
-			help:    "Total number of SQL queries pooled by pgbouncer.",
-			labels:  []string{"database"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, stat := range res.stats {
-					results = append(results, metricResult{
-						labels: []string{stat.Database},
-						value:  float64(stat.TotalQueryCount),
-					})
+			enabled:   cfg.ExportStats,
+			collector: config.CollectorStats,
+			name:      fqName("", "restarts_total"),
+			help:      "Number of pgbouncer restarts detected from decreasing stats counters since the exporter started.",
+			valType:   prometheus.CounterValue,
+			eval: func(res *storeResult) []metricResult {
+				return []metricResult{
+					{value: float64(res.restarts)},
 				}
-				return results
 			},
 		},
 		{
-			enabled: cfg.ExportStats,
-			name:    fqName(SubsystemStats, "total_xact_count"),
-			help:    "Total number of SQL transactions pooled by pgbouncer.",
-			labels:  []string{"database"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, stat := range res.stats {
-					results = append(results, metricResult{
-						labels: []string{stat.Database},
-						value:  float64(stat.TotalXactCount),
-					})
+			enabled:   cfg.ExportDatabases,
+			collector: config.CollectorDatabases,
+			name:      fqName(SubsystemDatabases, "count"),
+			help:      "Number of databases configured in pgbouncer.",
+			valType:   prometheus.GaugeValue,
+			eval: func(res *storeResult) []metricResult {
+				return []metricResult{
+					{value: float64(res.databaseCounts.count)},
 				}
-				return results
 			},
 		},
 		{
-			enabled: cfg.ExportPools,
-			name:    fqName(SubsystemPools, "active_clients"),
-			help:    "Client connections that are linked to server connection and can process queries.",
-			labels:  []string{"database", "user", "pool_mode"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, pool := range res.pools {
-					results = append(results, metricResult{
-						labels: []string{pool.Database, pool.User, pool.PoolMode},
-						value:  float64(pool.Active),
-					})
+			enabled:   cfg.ExportDatabases,
+			collector: config.CollectorDatabases,
+			name:      fqName(SubsystemDatabases, "added_total"),
+			help:      "Number of databases added to pgbouncer since the exporter started.",
+			valType:   prometheus.CounterValue,
+			eval: func(res *storeResult) []metricResult {
+				return []metricResult{
+					{value: float64(res.databaseCounts.added)},
 				}
-				return results
 			},
 		},
 		{
-			enabled: cfg.ExportPools,
-			name:    fqName(SubsystemPools, "waiting_clients"),
-			help:    "Client connections have sent queries but have not yet got a server connection.",
-			labels:  []string{"database", "user", "pool_mode"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, pool := range res.pools {
-					results = append(results, metricResult{
-						labels: []string{pool.Database, pool.User, pool.PoolMode},
-						value:  float64(pool.Waiting),
-					})
+			enabled:   cfg.ExportDatabases,
+			collector: config.CollectorDatabases,
+			name:      fqName(SubsystemDatabases, "removed_total"),
+			help:      "Number of databases removed from pgbouncer since the exporter started.",
+			valType:   prometheus.CounterValue,
+			eval: func(res *storeResult) []metricResult {
+				return []metricResult{
+					{value: float64(res.databaseCounts.removed)},
 				}
-				return results
 			},
 		},
 		{
-			enabled: cfg.ExportPools,
-			name:    fqName(SubsystemPools, "active_server"),
-			help:    "Server connections that are linked to a client.",
-			labels:  []string{"database", "user", "pool_mode"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, pool := range res.pools {
-					results = append(results, metricResult{
-						labels: []string{pool.Database, pool.User, pool.PoolMode},
-						value:  float64(pool.ServerActive),
-					})
+			enabled:   cfg.ExportPools,
+			collector: config.CollectorPools,
+			name:      fqName(SubsystemPools, "count"),
+			help:      "Number of pools in pgbouncer.",
+			valType:   prometheus.GaugeValue,
+			eval: func(res *storeResult) []metricResult {
+				return []metricResult{
+					{value: float64(res.poolCounts.count)},
 				}
-				return results
 			},
 		},
 		{
-			enabled: cfg.ExportPools,
-			name:    fqName(SubsystemPools, "idle_server"),
-			help:    "Server connections that are unused and immediately usable for client queries.",
-			labels:  []string{"database", "user", "pool_mode"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, pool := range res.pools {
-					results = append(results, metricResult{
-						labels: []string{pool.Database, pool.User, pool.PoolMode},
-						value:  float64(pool.ServerIdle),
-					})
+			enabled:   cfg.ExportPools,
+			collector: config.CollectorPools,
+			name:      fqName(SubsystemPools, "added_total"),
+			help:      "Number of pools added to pgbouncer since the exporter started.",
+			valType:   prometheus.CounterValue,
+			eval: func(res *storeResult) []metricResult {
+				return []metricResult{
+					{value: float64(res.poolCounts.added)},
 				}
-				return results
 			},
 		},
 		{
-			enabled: cfg.ExportPools,
-			name:    fqName(SubsystemPools, "used_server"),
-			help:    "Server connections that have been idle for more than server_check_delay, so they need server_check_query to run on them before they can be used again.",
-			labels:  []string{"database", "user", "pool_mode"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, pool := range res.pools {
-					results = append(results, metricResult{
-						labels: []string{pool.Database, pool.User, pool.PoolMode},
-						value:  float64(pool.ServerUsed),
-					})
+			enabled:   cfg.ExportPools,
+			collector: config.CollectorPools,
+			name:      fqName(SubsystemPools, "removed_total"),
+			help:      "Number of pools removed from pgbouncer since the exporter started.",
+			valType:   prometheus.CounterValue,
+			eval: func(res *storeResult) []metricResult {
+				return []metricResult{
+					{value: float64(res.poolCounts.removed)},
 				}
-				return results
 			},
 		},
 		{
-			enabled: cfg.ExportPools,
-			name:    fqName(SubsystemPools, "tested_server"),
-			help:    "Server connections that are currently running either server_reset_query or server_check_query.",
-			labels:  []string{"database", "user", "pool_mode"},
-			valType: prometheus.GaugeValue,
+			enabled:   cfg.ExportPools && cfg.ExportDatabases,
+			collector: config.CollectorPools,
+			name:      fqName(SubsystemPools, "server_utilization"),
+			help:      "Ratio of active server connections to the pool_size of the database, not exported when the database is missing or its pool_size is 0.",
+			labels:    []string{"database", "user", "pool_mode"},
+			valType:   prometheus.GaugeValue,
 			eval: func(res *storeResult) (results []metricResult) {
-				for _, pool := range res.pools {
-					results = append(results, metricResult{
-						labels: []string{pool.Database, pool.User, pool.PoolMode},
-						value:  float64(pool.ServerTested),
-					})
+				for _, row := range joinPoolsDatabases(res.pools, res.databases) {
+					if row.database == nil {
+						continue
+					}
+					// pool_size overrides of the users are not returned by SHOW DATABASES and are not taken into account
+					if value, ok := ratio(row.pool.ServerActive, row.database.PoolSize); ok {
+						results = append(results, metricResult{
+							labels: []string{row.pool.Database, row.pool.User, row.pool.PoolMode},
+							value:  value,
+						})
+					}
 				}
 				return results
 			},
 		},
 		{
-			enabled: cfg.ExportPools,
-			name:    fqName(SubsystemPools, "login_server"),
-			help:    "Server connections currently in the process of logging in.",
-			labels:  []string{"database", "user", "pool_mode"},
-			valType: prometheus.GaugeValue,
+			enabled:   cfg.ExportPools && cfg.ExportDatabases,
+			collector: config.CollectorPools,
+			name:      fqName(SubsystemPools, "client_utilization"),
+			help:      "Ratio of active and waiting client connections of all pools of the database to its max_client_connections, not exported when the database is missing or the limit is not set.",
+			labels:    []string{"database"},
+			valType:   prometheus.GaugeValue,
 			eval: func(res *storeResult) (results []metricResult) {
-				for _, pool := range res.pools {
-					results = append(results, metricResult{
-						labels: []string{pool.Database, pool.User, pool.PoolMode},
-						value:  float64(pool.ServerLogin),
-					})
+				// max_client_connections limits the clients of the database, so the pools of all users are summed
+				for _, sum := range sumDatabaseClients(joinPoolsDatabases(res.pools, res.databases)) {
+					if sum.database == nil {
+						continue
+					}
+					if value, ok := ratio(sum.clients, sum.database.MaxClientConnections); ok {
+						results = append(results, metricResult{
+							labels: []string{sum.name},
+							value:  value,
+						})
+					}
 				}
 				return results
 			},
 		},
 		{
-			enabled: cfg.ExportPools,
-			name:    fqName(SubsystemPools, "max_wait"),
-			help:    "How long the first (oldest) client in the queue has waited, in seconds. If this starts increasing, then the current pool of servers does not handle requests quickly enough. The reason may be either an overloaded server or just too small of a pool_size setting.",
-			labels:  []string{"database", "user", "pool_mode"},
-			valType: prometheus.GaugeValue,
+			enabled:   cfg.ExportPools,
+			collector: config.CollectorPools,
+			name:      fqName(SubsystemPools, "client_waiting_ratio"),
+			help:      "Ratio of waiting client connections to active client connections, not exported when there are no active clients.",
+			labels:    []string{"database", "user", "pool_mode"},
+			valType:   prometheus.GaugeValue,
 			eval: func(res *storeResult) (results []metricResult) {
 				for _, pool := range res.pools {
-					results = append(results, metricResult{
-						labels: []string{pool.Database, pool.User, pool.PoolMode},
-						value:  float64(pool.MaxWait),
-					})
-				}
-				return results
-			},
-		},
-		{
-			enabled: cfg.ExportDatabases,
-			name:    fqName(SubsystemDatabases, "pool_size"),
-			help:    "Maximum number of server connections.",
-			labels:  []string{"name", "pool_mode"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, database := range res.databases {
-					results = append(results, metricResult{
-						labels: []string{database.Name, database.PoolMode},
-						value:  float64(database.PoolSize),
-					})
+					if value, ok := ratio(pool.Waiting, pool.Active); ok {
+						results = append(results, metricResult{
+							labels: []string{pool.Database, pool.User, pool.PoolMode},
+							value:  value,
+						})
+					}
 				}
 				return results
 			},
 		},
 		{
-			enabled: cfg.ExportDatabases,
-			name:    fqName(SubsystemDatabases, "current_connections"),
-			help:    "Current number of connections for this database.",
-			labels:  []string{"name", "pool_mode"},
-			valType: prometheus.GaugeValue,
+			enabled: true,
+			name:    fqName("", "unknown_columns_total"),
+			help:    "Number of times the admin console returned a column not known to the exporter, counted once per command result.",
+			labels:  []string{"command", "column"},
+			valType: prometheus.CounterValue,
 			eval: func(res *storeResult) (results []metricResult) {
-				for _, database := range res.databases {
+				for _, col := range res.unknownColumns {
 					results = append(results, metricResult{
-						labels: []string{database.Name, database.PoolMode},
-						value:  float64(database.CurrentConnections),
+						labels: []string{col.Command, col.Name},
+						value:  float64(col.Count),
 					})
 				}
 				return results
 			},
 		},
 		{
-			enabled: cfg.ExportDatabases,
-			name:    fqName(SubsystemDatabases, "max_connections"),
-			help:    "Maximum number of allowed connections for this database.",
-			labels:  []string{"name", "pool_mode"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, database := range res.databases {
-					results = append(results, metricResult{
-						labels: []string{database.Name, database.PoolMode},
-						value:  float64(database.MaxConnections),
-					})
+			enabled:   cfg.ExportTotals,
+			collector: config.CollectorTotals,
+			name:      fqName(SubsystemTotals, "error"),
+			help:      "Whether the last SHOW TOTALS failed (1 for error, 0 for success).",
+			valType:   prometheus.GaugeValue,
+			eval: func(res *storeResult) []metricResult {
+				var value float64
+				if res.totalsErr != nil {
+					value = 1
 				}
-				return results
-			},
-		},
-		{
-			enabled: cfg.ExportDatabases,
-			name:    fqName(SubsystemDatabases, "server_lifetime"),
-			help:    "The maximum lifetime of a server connection for this database.",
-			labels:  []string{"name", "pool_mode"},
-			valType: prometheus.GaugeValue,
-			eval: func(res *storeResult) (results []metricResult) {
-				for _, database := range res.databases {
-					results = append(results, metricResult{
-						labels: []string{database.Name, database.PoolMode},
-						value:  float64(database.ServerLifetime),
-					})
+				return []metricResult{
+					{value: value},
 				}
-				return results
 			},
 		},
 		{
-			enabled: cfg.ExportLists,
-			name:    fqName(SubsystemLists, "items"),
-			help:    "List of internal pgbouncer information.",
-			labels:  []string{"list"},
+			enabled: len(cfg.Queries) > 0,
+			name:    fqName("", "custom_query_error"),
+			help:    "Whether the last run of the custom query failed (1 for error, 0 for success).",
+			labels:  []string{"command"},
 			valType: prometheus.GaugeValue,
 			eval: func(res *storeResult) (results []metricResult) {
-				for _, list := range res.lists {
+				for _, q := range res.queries {
+					var value float64
+					if q.err != nil {
+						value = 1
+					}
 					results = append(results, metricResult{
-						labels: []string{list.List},
-						value:  float64(list.Items),
+						labels: []string{q.command},
+						value:  value,
 					})
 				}
 				return results
 			},
 		},
-	}
+	}...)
 }
 
 func fqName(subsystem string, name string) string {
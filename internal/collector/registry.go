@@ -6,8 +6,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus/collectors/version"
 )
 
-// NewRegistry returns new prometheus registry with registered Exporter and common exporters.
-func NewRegistry(exp *Exporter) prometheus.Gatherer {
+// NewRegistry returns new prometheus registry with registered Exporters and common exporters.
+func NewRegistry(exps ...*Exporter) *prometheus.Registry {
 	reg := prometheus.NewRegistry()
 	reg.MustRegister(version.NewCollector(Name))
 	reg.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{
@@ -15,6 +15,18 @@ func NewRegistry(exp *Exporter) prometheus.Gatherer {
 		ReportErrors: false,
 	}))
 	reg.MustRegister(collectors.NewGoCollector())
-	reg.MustRegister(exp)
+	for _, exp := range exps {
+		reg.MustRegister(exp)
+	}
+	return reg
+}
+
+// NewExporterRegistry returns new prometheus registry with registered Exporters only,
+// without the go and process metrics of the exporter itself.
+func NewExporterRegistry(exps ...*Exporter) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	for _, exp := range exps {
+		reg.MustRegister(exp)
+	}
 	return reg
 }
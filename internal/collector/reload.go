@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	_ prometheus.Collector = &Reloader{}
+)
+
+// Reloader reloads the configuration of Exporters and tracks the status of the last reload.
+type Reloader struct {
+	load func() (config.Config, error)
+	exps []*Exporter
+
+	mut              sync.Mutex // guards Reload
+	cfg              config.Config
+	lastSuccessful   prometheus.Gauge
+	lastSuccessfulTs prometheus.Gauge
+}
+
+// NewReloader returns new Reloader, exps have to be created from the targets of cfg in the same order.
+func NewReloader(cfg config.Config, load func() (config.Config, error), exps ...*Exporter) *Reloader {
+	r := &Reloader{
+		load: load,
+		exps: exps,
+		cfg:  cfg,
+		lastSuccessful: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: fqName("config", "last_reload_successful"),
+			Help: "Whether the last configuration reload attempt was successful.",
+		}),
+		lastSuccessfulTs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: fqName("config", "last_reload_success_timestamp_seconds"),
+			Help: "Timestamp of the last successful configuration reload.",
+		}),
+	}
+	r.lastSuccessful.Set(1)
+	r.lastSuccessfulTs.SetToCurrentTime()
+	return r
+}
+
+// Reload loads the configuration and applies it to all the Exporters.
+func (r *Reloader) Reload() error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if err := r.reload(); err != nil {
+		r.lastSuccessful.Set(0)
+		return err
+	}
+
+	r.lastSuccessful.Set(1)
+	r.lastSuccessfulTs.SetToCurrentTime()
+	return nil
+}
+
+func (r *Reloader) reload() error {
+	cfg, err := r.load()
+	if err != nil {
+		return fmt.Errorf("could not load config: %v", err)
+	}
+	if cfg.Store != r.cfg.Store || cfg.StrictColumns != r.cfg.StrictColumns || cfg.LogFile != r.cfg.LogFile || cfg.LogStatsPeriod != r.cfg.LogStatsPeriod {
+		return errors.New("store settings changed, restart required")
+	}
+	if !reflect.DeepEqual(cfg.Mappings, r.cfg.Mappings) {
+		// the stores were created with the columns of the mappings
+		return errors.New("metric mappings changed, restart required")
+	}
+	if err := checkTargets(r.cfg.Targets, cfg.Targets); err != nil {
+		return err
+	}
+	if cfg.ListenAddress != r.cfg.ListenAddress || cfg.TelemetryPath != r.cfg.TelemetryPath || cfg.EnableLifecycle != r.cfg.EnableLifecycle ||
+		cfg.EnableSnapshot != r.cfg.EnableSnapshot {
+		log.Println("Changes of web settings require restart, ignoring them")
+	}
+
+	for _, target := range cfg.Targets {
+		if err := ValidateConfig(cfg.WithTarget(target)); err != nil {
+			return err
+		}
+	}
+	for i, exp := range r.exps {
+		exp.ApplyConfig(cfg.WithTarget(cfg.Targets[i]))
+	}
+	r.cfg = cfg
+	return nil
+}
+
+// checkTargets checks that the connection settings of the targets did not change,
+// only their labels can be changed without restart.
+func checkTargets(prev []config.Target, next []config.Target) error {
+	if len(prev) != len(next) {
+		return errors.New("number of targets changed, restart required")
+	}
+	for i := range prev {
+		a, b := prev[i], next[i]
+		a.Labels, b.Labels = nil, nil
+		if !reflect.DeepEqual(a, b) {
+			return fmt.Errorf("connection settings of target %v changed, restart required", prev[i])
+		}
+	}
+	return nil
+}
+
+// Describe implements prometheus Collector.Describe.
+func (r *Reloader) Describe(ch chan<- *prometheus.Desc) {
+	r.lastSuccessful.Describe(ch)
+	r.lastSuccessfulTs.Describe(ch)
+}
+
+// Collect implements prometheus Collector.Collect.
+func (r *Reloader) Collect(ch chan<- prometheus.Metric) {
+	r.lastSuccessful.Collect(ch)
+	r.lastSuccessfulTs.Collect(ch)
+}
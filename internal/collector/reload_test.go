@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/mapping"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	target := config.Target{Name: "main", DatabaseURL: "postgres://localhost"}
+	cfg := config.Config{
+		StoreTimeout:  time.Second,
+		ExportStats:   true,
+		DefaultLabels: map[string]string{"env": "dev"},
+		Targets:       []config.Target{target},
+	}
+
+	exp := New(cfg.WithTarget(target), nil)
+
+	next := cfg
+	var loadErr error
+	reloader := NewReloader(cfg, func() (config.Config, error) { return next, loadErr }, exp)
+	require.Equal(t, float64(1), testutil.ToFloat64(reloader.lastSuccessful))
+
+	next.DefaultLabels = map[string]string{"env": "prod"}
+	next.ExportStats = false
+	next.ExportPools = true
+	next.Targets = []config.Target{
+		{Name: "main", DatabaseURL: "postgres://localhost", Labels: map[string]string{"instance": "pg1"}},
+	}
+	require.NoError(t, reloader.Reload())
+	require.Equal(t, float64(1), testutil.ToFloat64(reloader.lastSuccessful))
+	require.Equal(t, prometheus.Labels{"env": "prod", "instance": "pg1"}, exp.constLabels)
+	for _, met := range exp.metrics {
+		if met.enabled && met.collector != "" {
+			require.Contains(t, met.name, SubsystemPools)
+		}
+	}
+
+	loadErr = errors.New("invalid config")
+	require.EqualError(t, reloader.Reload(), "could not load config: invalid config")
+	require.Equal(t, float64(0), testutil.ToFloat64(reloader.lastSuccessful))
+	require.Equal(t, prometheus.Labels{"env": "prod", "instance": "pg1"}, exp.constLabels)
+
+	loadErr = nil
+	next.Targets = []config.Target{
+		{Name: "main", DatabaseURL: "postgres://localhost", Labels: map[string]string{"user": "pg1"}},
+	}
+	require.EqualError(t, reloader.Reload(), `constant label "user" collides with the label of metric pgbouncer_exporter_pools_active_clients`)
+
+	next.Targets = []config.Target{{Name: "main", DatabaseURL: "postgres://localhost"}}
+	next.Mappings = []mapping.Mapping{{Command: "SHOW POOLS", Column: "sv_new", Metric: "pools_new_server", Type: mapping.TypeGauge, Scale: 1}}
+	require.EqualError(t, reloader.Reload(), "metric mappings changed, restart required")
+	next.Mappings = nil
+
+	next.Targets = []config.Target{{Name: "main", DatabaseURL: "postgres://remote"}}
+	require.EqualError(t, reloader.Reload(), "connection settings of target main changed, restart required")
+	require.Equal(t, float64(0), testutil.ToFloat64(reloader.lastSuccessful))
+
+	next.Targets = append(next.Targets, config.Target{Name: "other", DatabaseURL: "postgres://other"})
+	require.EqualError(t, reloader.Reload(), "number of targets changed, restart required")
+}
@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+)
+
+// detectRestart reports whether pgbouncer was restarted between the two stats snapshots.
+// PgBouncer does not expose its start time in the admin console, so the restart is detected
+// when the counters of all the databases which had any traffic before decreased, a single
+// database being removed and added back by RELOAD resets only its own counters.
+func detectRestart(prev map[string]domain.Stat, cur []domain.Stat) bool {
+	var active, regressed int
+	for _, stat := range cur {
+		p, ok := prev[stat.Database]
+		if !ok || !hasTraffic(p) {
+			continue
+		}
+		active++
+		if countersDecreased(p, stat) {
+			regressed++
+		}
+	}
+	return active > 0 && regressed == active
+}
+
+func hasTraffic(stat domain.Stat) bool {
+	return stat.TotalXactCount > 0 || stat.TotalQueryCount > 0 || stat.TotalReceived > 0 || stat.TotalSent > 0
+}
+
+func countersDecreased(prev domain.Stat, cur domain.Stat) bool {
+	return cur.TotalXactCount < prev.TotalXactCount ||
+		cur.TotalQueryCount < prev.TotalQueryCount ||
+		cur.TotalReceived < prev.TotalReceived ||
+		cur.TotalSent < prev.TotalSent ||
+		cur.TotalXactTime < prev.TotalXactTime ||
+		cur.TotalQueryTime < prev.TotalQueryTime
+}
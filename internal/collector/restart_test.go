@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	detectRestartCases = []struct {
+		name     string
+		prev     map[string]domain.Stat
+		cur      []domain.Stat
+		expected bool
+	}{
+		{
+			name: "first scrape",
+			prev: nil,
+			cur: []domain.Stat{
+				{Database: "main", TotalQueryCount: 10},
+			},
+			expected: false,
+		},
+		{
+			name: "counters increased",
+			prev: map[string]domain.Stat{
+				"main":      {Database: "main", TotalQueryCount: 10, TotalSent: 100},
+				"pgbouncer": {Database: "pgbouncer", TotalQueryCount: 5},
+			},
+			cur: []domain.Stat{
+				{Database: "main", TotalQueryCount: 20, TotalSent: 200},
+				{Database: "pgbouncer", TotalQueryCount: 6},
+			},
+			expected: false,
+		},
+		{
+			name: "counters of all databases decreased",
+			prev: map[string]domain.Stat{
+				"main":      {Database: "main", TotalQueryCount: 10, TotalSent: 100},
+				"pgbouncer": {Database: "pgbouncer", TotalQueryCount: 5},
+				"idle":      {Database: "idle"},
+			},
+			cur: []domain.Stat{
+				{Database: "main", TotalQueryCount: 1, TotalSent: 10},
+				{Database: "pgbouncer", TotalQueryCount: 1},
+				{Database: "idle"},
+			},
+			expected: true,
+		},
+		{
+			name: "counters of single database decreased",
+			prev: map[string]domain.Stat{
+				"main":      {Database: "main", TotalQueryCount: 10},
+				"pgbouncer": {Database: "pgbouncer", TotalQueryCount: 5},
+			},
+			cur: []domain.Stat{
+				{Database: "main", TotalQueryCount: 0},
+				{Database: "pgbouncer", TotalQueryCount: 6},
+			},
+			expected: false,
+		},
+		{
+			name: "databases without traffic",
+			prev: map[string]domain.Stat{
+				"main": {Database: "main"},
+			},
+			cur: []domain.Stat{
+				{Database: "main"},
+			},
+			expected: false,
+		},
+	}
+)
+
+func TestDetectRestart(t *testing.T) {
+	for _, cs := range detectRestartCases {
+		t.Run(cs.name, func(t *testing.T) {
+			require.Equal(t, cs.expected, detectRestart(cs.prev, cs.cur))
+		})
+	}
+}
+
+func TestStatsHistoryObserve(t *testing.T) {
+	var h statsHistory
+
+	start := time.Unix(1000, 0)
+	snap := h.observe([]domain.Stat{{Database: "main", TotalQueryCount: 10}}, start)
+	require.Nil(t, snap.prevStats)
+	require.True(t, snap.startTime.IsZero())
+	require.Zero(t, snap.restarts)
+
+	snap = h.observe([]domain.Stat{{Database: "main", TotalQueryCount: 20}}, start.Add(time.Minute))
+	require.Equal(t, int64(10), snap.prevStats["main"].TotalQueryCount)
+	require.True(t, snap.startTime.IsZero())
+	require.Zero(t, snap.restarts)
+
+	restart := start.Add(2 * time.Minute)
+	snap = h.observe([]domain.Stat{{Database: "main", TotalQueryCount: 1}}, restart)
+	require.Equal(t, restart, snap.startTime)
+	require.Equal(t, int64(1), snap.restarts)
+}
+
+func TestCollectRestarts(t *testing.T) {
+	st := &testStore{
+		stats: []domain.Stat{
+			{Database: "main", TotalQueryCount: 100},
+		},
+	}
+
+	cfg := config.Config{
+		StoreTimeout: time.Second,
+		ExportStats:  true,
+	}
+
+	exp := New(cfg, st)
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(exp)
+
+	expected := `
+# HELP pgbouncer_exporter_restarts_total Number of pgbouncer restarts detected from decreasing stats counters since the exporter started.
+# TYPE pgbouncer_exporter_restarts_total counter
+pgbouncer_exporter_restarts_total 0
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "pgbouncer_exporter_restarts_total"))
+
+	// the start time is not known before the first detected restart
+	count, err := testutil.GatherAndCount(reg, "pgbouncer_exporter_start_time_seconds")
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	st.stats = []domain.Stat{
+		{Database: "main", TotalQueryCount: 3},
+	}
+
+	expected = `
+# HELP pgbouncer_exporter_restarts_total Number of pgbouncer restarts detected from decreasing stats counters since the exporter started.
+# TYPE pgbouncer_exporter_restarts_total counter
+pgbouncer_exporter_restarts_total 1
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "pgbouncer_exporter_restarts_total"))
+
+	count, err = testutil.GatherAndCount(reg, "pgbouncer_exporter_start_time_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
@@ -0,0 +1,231 @@
+package collector
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+)
+
+// Snapshot represents the raw rows read from the store of a target.
+type Snapshot struct {
+	Labels     map[string]string          `json:"labels,omitempty"`
+	Timestamp  time.Time                  `json:"timestamp"`
+	Collectors map[string]SnapshotSection `json:"collectors"`
+	Queries    map[string]SnapshotSection `json:"queries,omitempty"`
+}
+
+// SnapshotSection represents the rows of a single admin console command, Error is set when the command failed.
+// Columns are the names of the columns of the rows in the order returned by the store, the names of the
+// columns without a value in the first row follow in the order of the rows they first appear in.
+type SnapshotSection struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Error     string           `json:"error,omitempty"`
+	Columns   []string         `json:"columns"`
+	Rows      []map[string]any `json:"rows"`
+}
+
+// SnapshotFilter selects the rows of a Snapshot by their database and user, all rows are selected when empty.
+// Rows without the database or user column are always selected.
+type SnapshotFilter struct {
+	Databases []string
+	Users     []string
+}
+
+// matchColumn reports whether the value of the column is one of values, ok is false when the row has no such column.
+func matchColumn(values []string, value string, ok bool) bool {
+	return !ok || len(values) == 0 || slices.Contains(values, value)
+}
+
+// matchFilters reports whether the value of the column matches all filters, ok is false when the row has no such column.
+func matchFilters(filters []config.Filter, value string, ok bool) bool {
+	if !ok {
+		return true
+	}
+	for _, f := range filters {
+		if !f.Match(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// rowFilter selects the rows of a snapshot section, the rows filtered out of the metrics
+// by the configured database and user filters are never selected.
+type rowFilter struct {
+	SnapshotFilter
+	databaseColumn string
+	userColumn     string
+	databases      []config.Filter
+	users          []config.Filter
+}
+
+func (f rowFilter) match(columns map[string]any) bool {
+	database, hasDatabase := columns[f.databaseColumn].(string)
+	user, hasUser := columns[f.userColumn].(string)
+	return matchColumn(f.Databases, database, hasDatabase) && matchColumn(f.Users, user, hasUser) &&
+		matchFilters(f.databases, database, hasDatabase) && matchFilters(f.users, user, hasUser)
+}
+
+// snapshotSource returns the rows of a collector from the store result, the database and user columns are used for filtering.
+type snapshotSource struct {
+	collector      string
+	enabled        func(cfg config.Config) bool
+	databaseColumn string
+	userColumn     string
+	rows           func(res *storeResult) ([]any, error)
+}
+
+var snapshotSources = []snapshotSource{
+	{
+		collector:      config.CollectorStats,
+		enabled:        func(cfg config.Config) bool { return cfg.ExportStats },
+		databaseColumn: "database",
+		rows: func(res *storeResult) ([]any, error) {
+			return toAny(res.stats), nil
+		},
+	},
+	{
+		collector:      config.CollectorPools,
+		enabled:        func(cfg config.Config) bool { return cfg.ExportPools },
+		databaseColumn: "database",
+		userColumn:     "user",
+		rows: func(res *storeResult) ([]any, error) {
+			return toAny(res.pools), nil
+		},
+	},
+	{
+		collector:      config.CollectorDatabases,
+		enabled:        func(cfg config.Config) bool { return cfg.ExportDatabases },
+		databaseColumn: "name",
+		rows: func(res *storeResult) ([]any, error) {
+			return toAny(res.databases), nil
+		},
+	},
+	{
+		collector: config.CollectorLists,
+		enabled:   func(cfg config.Config) bool { return cfg.ExportLists },
+		rows: func(res *storeResult) ([]any, error) {
+			return toAny(res.lists), nil
+		},
+	},
+	{
+		collector: config.CollectorTotals,
+		enabled:   func(cfg config.Config) bool { return cfg.ExportTotals },
+		rows: func(res *storeResult) ([]any, error) {
+			if res.totalsErr != nil || res.totals == nil {
+				return nil, res.totalsErr
+			}
+			return []any{*res.totals}, nil
+		},
+	},
+}
+
+func toAny[T any](rows []T) []any {
+	res := make([]any, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, row)
+	}
+	return res
+}
+
+// Snapshot returns the rows of the enabled collectors and of the custom queries read by the last Collect,
+// failing commands are reported in their section instead of failing the snapshot. Until the first Collect,
+// for example in the show command, the rows are read from the store, unlike Collect this does not update
+// the state used to compute the metrics, like the detected restarts. The rows are filtered by filter
+// and by the database and user filters of the config.
+func (e *Exporter) Snapshot(ctx context.Context, filter SnapshotFilter) *Snapshot {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	last := e.last
+	if last == nil {
+		ctx, cancel := context.WithTimeout(ctx, e.cfg.StoreTimeout)
+		defer cancel()
+
+		res, err := e.readStore(ctx)
+		last = &scrapeResult{res: res, err: err, timestamp: time.Now()}
+	}
+
+	snap := &Snapshot{
+		Labels:     e.constLabels,
+		Timestamp:  last.timestamp,
+		Collectors: make(map[string]SnapshotSection),
+	}
+
+	for _, src := range snapshotSources {
+		if !src.enabled(e.cfg) {
+			continue
+		}
+		collectorFilters := e.cfg.CollectorFilters[src.collector]
+		rf := rowFilter{
+			SnapshotFilter: filter,
+			databaseColumn: src.databaseColumn,
+			userColumn:     src.userColumn,
+			databases:      []config.Filter{e.cfg.Filters.Databases, collectorFilters.Databases},
+			users:          []config.Filter{e.cfg.Filters.Users, collectorFilters.Users},
+		}
+		rows, err := last.rows(src.rows)
+		snap.Collectors[src.collector] = newSnapshotSection(rows, err, last.timestamp, rf)
+	}
+
+	if len(e.cfg.Queries) > 0 {
+		snap.Queries = make(map[string]SnapshotSection, len(e.cfg.Queries))
+	}
+	for _, q := range e.cfg.Queries {
+		rf := rowFilter{
+			SnapshotFilter: filter,
+			databaseColumn: "database",
+			userColumn:     "user",
+			databases:      []config.Filter{e.cfg.Filters.Databases},
+			users:          []config.Filter{e.cfg.Filters.Users},
+		}
+		rows, err := last.rows(func(res *storeResult) ([]any, error) {
+			for _, qr := range res.queries {
+				if qr.command == q.Command {
+					return toAny(qr.rows), qr.err
+				}
+			}
+			return nil, nil
+		})
+		snap.Queries[q.Command] = newSnapshotSection(rows, err, last.timestamp, rf)
+	}
+	return snap
+}
+
+// rows returns the rows of the store result, the error of the failed scrape is returned for all of them.
+func (r *scrapeResult) rows(get func(res *storeResult) ([]any, error)) ([]any, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return get(r.res)
+}
+
+func newSnapshotSection(rows []any, err error, timestamp time.Time, filter rowFilter) SnapshotSection {
+	section := SnapshotSection{
+		Timestamp: timestamp,
+		Columns:   make([]string, 0),
+		Rows:      make([]map[string]any, 0, len(rows)),
+	}
+	if err != nil {
+		section.Error = err.Error()
+		return section
+	}
+
+	for _, row := range rows {
+		names, columns := domain.Columns(row)
+		for _, name := range names {
+			if !slices.Contains(section.Columns, name) {
+				section.Columns = append(section.Columns, name)
+			}
+		}
+
+		if !filter.match(columns) {
+			continue
+		}
+		section.Rows = append(section.Rows, columns)
+	}
+	return section
+}
@@ -0,0 +1,217 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+	"github.com/jbub/pgbouncer_exporter/internal/mapping"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newSnapshotStore() *testStore {
+	var (
+		statColumns     = domain.Row{Columns: []string{"database", "total_query_count"}}
+		poolColumns     = domain.Row{Columns: []string{"database", "user", "cl_active"}}
+		databaseColumns = domain.Row{Columns: []string{"name", "pool_size"}}
+	)
+	return &testStore{
+		stats: []domain.Stat{
+			{Database: "app", TotalQueryCount: 10, Extra: statColumns},
+			{Database: "pgbouncer", TotalQueryCount: 1, Extra: statColumns},
+		},
+		pools: []domain.Pool{
+			{Database: "app", User: "app", Active: 3, Extra: poolColumns},
+			{Database: "app", User: "admin", Active: 1, Extra: poolColumns},
+			{Database: "pgbouncer", User: "pgbouncer", Extra: poolColumns},
+		},
+		databases: []domain.Database{{Name: "app", PoolSize: 20, Extra: databaseColumns}, {Name: "pgbouncer", Extra: databaseColumns}},
+		lists:     []domain.List{{List: "pools", Items: 3, Extra: domain.Row{Columns: []string{"list", "items"}}}},
+		totals:    &domain.Totals{TotalQueryCount: 11, Extra: domain.Row{Columns: []string{"total_query_count", "total_xact_count"}}},
+		queries: map[string][]domain.Row{
+			"SHOW MEM": {{Labels: map[string]string{"name": "user_cache"}, Values: domain.Values{"size": 512}}},
+		},
+	}
+}
+
+var (
+	snapshotCases = []struct {
+		name     string
+		filter   SnapshotFilter
+		expected map[string][]string
+	}{
+		{
+			name: "all rows",
+			expected: map[string][]string{
+				config.CollectorStats:     {"app", "pgbouncer"},
+				config.CollectorPools:     {"app/app", "app/admin", "pgbouncer/pgbouncer"},
+				config.CollectorDatabases: {"app", "pgbouncer"},
+			},
+		},
+		{
+			name:   "database",
+			filter: SnapshotFilter{Databases: []string{"app"}},
+			expected: map[string][]string{
+				config.CollectorStats:     {"app"},
+				config.CollectorPools:     {"app/app", "app/admin"},
+				config.CollectorDatabases: {"app"},
+			},
+		},
+		{
+			name:   "database and user",
+			filter: SnapshotFilter{Databases: []string{"app", "pgbouncer"}, Users: []string{"admin", "pgbouncer"}},
+			expected: map[string][]string{
+				config.CollectorStats:     {"app", "pgbouncer"},
+				config.CollectorPools:     {"app/admin", "pgbouncer/pgbouncer"},
+				config.CollectorDatabases: {"app", "pgbouncer"},
+			},
+		},
+	}
+)
+
+func TestSnapshotFilter(t *testing.T) {
+	cfg := config.Config{
+		StoreTimeout:    time.Second,
+		ExportStats:     true,
+		ExportPools:     true,
+		ExportDatabases: true,
+	}
+	exp := New(cfg, newSnapshotStore())
+
+	for _, cs := range snapshotCases {
+		t.Run(cs.name, func(t *testing.T) {
+			snap := exp.Snapshot(context.Background(), cs.filter)
+
+			keys := make(map[string][]string)
+			for _, row := range snap.Collectors[config.CollectorStats].Rows {
+				keys[config.CollectorStats] = append(keys[config.CollectorStats], row["database"].(string))
+			}
+			for _, row := range snap.Collectors[config.CollectorPools].Rows {
+				keys[config.CollectorPools] = append(keys[config.CollectorPools], row["database"].(string)+"/"+row["user"].(string))
+			}
+			for _, row := range snap.Collectors[config.CollectorDatabases].Rows {
+				keys[config.CollectorDatabases] = append(keys[config.CollectorDatabases], row["name"].(string))
+			}
+			require.Equal(t, cs.expected, keys)
+		})
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	cfg := config.Config{
+		StoreTimeout:  time.Second,
+		ExportLists:   true,
+		ExportTotals:  true,
+		DefaultLabels: map[string]string{"instance": "pg1"},
+		Queries: []mapping.Query{
+			{Command: "SHOW MEM"},
+			{Command: "SHOW UNKNOWN"},
+		},
+	}
+	exp := New(cfg, newSnapshotStore())
+
+	snap := exp.Snapshot(context.Background(), SnapshotFilter{Databases: []string{"app"}})
+	require.Equal(t, map[string]string{"instance": "pg1"}, snap.Labels)
+	require.False(t, snap.Timestamp.IsZero())
+
+	// only the enabled collectors are read
+	require.Len(t, snap.Collectors, 2)
+
+	lists := snap.Collectors[config.CollectorLists]
+	require.Empty(t, lists.Error)
+	require.False(t, lists.Timestamp.IsZero())
+	require.Equal(t, []string{"list", "items"}, lists.Columns)
+	require.Equal(t, []map[string]any{{"list": "pools", "items": int64(3)}}, lists.Rows)
+
+	// only the scanned fields are included in the order returned by the store
+	totals := snap.Collectors[config.CollectorTotals]
+	require.Equal(t, []string{"total_query_count", "total_xact_count"}, totals.Columns)
+	require.Equal(t, []map[string]any{{"total_xact_count": int64(0), "total_query_count": int64(11)}}, totals.Rows)
+
+	require.Equal(t, []string{"name", "size"}, snap.Queries["SHOW MEM"].Columns)
+	require.Equal(t, []map[string]any{{"name": "user_cache", "size": 512.0}}, snap.Queries["SHOW MEM"].Rows)
+
+	unknown := snap.Queries["SHOW UNKNOWN"]
+	require.Equal(t, "invalid command 'SHOW UNKNOWN', use SHOW HELP;", unknown.Error)
+	require.Empty(t, unknown.Rows)
+}
+
+func TestSnapshotLastCollect(t *testing.T) {
+	cfg := config.Config{
+		StoreTimeout: time.Second,
+		ExportStats:  true,
+		Queries:      []mapping.Query{{Command: "SHOW MEM"}},
+	}
+	stor := newSnapshotStore()
+	exp := New(cfg, stor)
+	testutil.CollectAndCount(exp)
+
+	// the rows of the last collect are served instead of reading the store
+	stor.stats = nil
+	stor.queries = nil
+	snap := exp.Snapshot(context.Background(), SnapshotFilter{})
+	require.Len(t, snap.Collectors[config.CollectorStats].Rows, 2)
+	require.Empty(t, snap.Queries["SHOW MEM"].Error)
+	require.Len(t, snap.Queries["SHOW MEM"].Rows, 1)
+
+	// the store is read again after the config changes until the next collect
+	exp.ApplyConfig(cfg)
+	snap = exp.Snapshot(context.Background(), SnapshotFilter{})
+	require.Empty(t, snap.Collectors[config.CollectorStats].Rows)
+	require.Equal(t, "invalid command 'SHOW MEM', use SHOW HELP;", snap.Queries["SHOW MEM"].Error)
+}
+
+func TestSnapshotConfigFilters(t *testing.T) {
+	cfg := config.Config{
+		StoreTimeout:    time.Second,
+		ExportStats:     true,
+		ExportPools:     true,
+		ExportDatabases: true,
+		Filters: config.Filters{
+			Databases: mustCompileFilter(nil, []string{"pgbouncer"}),
+		},
+		CollectorFilters: map[string]config.Filters{
+			config.CollectorPools: {Users: mustCompileFilter([]string{"app"}, nil)},
+		},
+	}
+	exp := New(cfg, newSnapshotStore())
+
+	// the rows filtered out of the metrics are not included
+	snap := exp.Snapshot(context.Background(), SnapshotFilter{})
+	require.Len(t, snap.Collectors[config.CollectorStats].Rows, 1)
+	require.Equal(t, "app", snap.Collectors[config.CollectorStats].Rows[0]["database"])
+	require.Equal(t, []map[string]any{{"database": "app", "user": "app", "cl_active": int64(3)}}, snap.Collectors[config.CollectorPools].Rows)
+	require.Len(t, snap.Collectors[config.CollectorDatabases].Rows, 1)
+	require.Equal(t, "app", snap.Collectors[config.CollectorDatabases].Rows[0]["name"])
+}
+
+func TestSnapshotColumnNames(t *testing.T) {
+	cfg := config.Config{
+		StoreTimeout:    time.Second,
+		ExportDatabases: true,
+	}
+	exp := New(cfg, &testStore{
+		databases: []domain.Database{{
+			Name:            "app",
+			ReservePoolSize: 5,
+			Extra: domain.Row{
+				Labels:  map[string]string{"auth_user": "pgbouncer"},
+				Columns: []string{"name", "auth_user", "auth_query", "reserve_pool", "server_lifetime"},
+			},
+		}},
+	})
+
+	// the columns use the names returned by the store, the NULL extra columns are left out
+	databases := exp.Snapshot(context.Background(), SnapshotFilter{}).Collectors[config.CollectorDatabases]
+	require.Equal(t, []string{"name", "auth_user", "reserve_pool", "server_lifetime"}, databases.Columns)
+	require.Equal(t, []map[string]any{{
+		"name":            "app",
+		"auth_user":       "pgbouncer",
+		"reserve_pool":    int64(5),
+		"server_lifetime": int64(0),
+	}}, databases.Rows)
+}
@@ -1,35 +1,365 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/jbub/pgbouncer_exporter/internal/mapping"
+
 	"github.com/urfave/cli/v2"
 )
 
-func LoadFromCLI(ctx *cli.Context) Config {
-	return Config{
-		ListenAddress:   ctx.String("web.listen-address"),
-		TelemetryPath:   ctx.String("web.telemetry-path"),
-		DatabaseURL:     ctx.String("database-url"),
-		StoreTimeout:    ctx.Duration("store-timeout"),
-		ExportStats:     ctx.Bool("export-stats"),
-		ExportPools:     ctx.Bool("export-pools"),
-		ExportDatabases: ctx.Bool("export-databases"),
-		ExportLists:     ctx.Bool("export-lists"),
-		DefaultLabels:   ctx.String("default-labels"),
+// LoadFromCLI loads the configuration from the config file and command line flags.
+// Flags which were explicitly set take precedence over values from the config file.
+func LoadFromCLI(ctx *cli.Context) (Config, error) {
+	var cfg Config
+	if err := applyFlags(ctx, &cfg, func(string) bool { return true }); err != nil {
+		return Config{}, err
+	}
+
+	if path := ctx.String("config.file"); path != "" {
+		file, err := readFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		if err := file.apply(&cfg); err != nil {
+			return Config{}, fmt.Errorf("invalid config file %v: %v", path, err)
+		}
+		if err := applyFlags(ctx, &cfg, ctx.IsSet); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if cfg.MappingsFile != "" {
+		mappings, err := mapping.Load(cfg.MappingsFile)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Mappings = mappings
+	}
+
+	if cfg.QueriesFile != "" {
+		queries, err := mapping.LoadQueries(cfg.QueriesFile)
+		if err != nil {
+			return Config{}, err
+		}
+		if err := mapping.CheckConflicts(cfg.Mappings, mapping.QueryMappings(queries)); err != nil {
+			return Config{}, fmt.Errorf("invalid queries file %v: %v", cfg.QueriesFile, err)
+		}
+		cfg.Queries = queries
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// targetFlags are the flags which define the target, when any of them is set
+// the targets from the config file are replaced by the target defined using flags.
+var targetFlags = []string{
+	"database-url",
+	"database-url-file",
+	"database-socket-dir",
+}
+
+// targetOptionFlags are the flags which complete the target defined using targetFlags,
+// they can not be applied to the targets from the config file.
+var targetOptionFlags = []string{
+	"database-password-file",
+	"database-port",
+	"database-user",
+	"database-name",
+}
+
+type filterFlag struct {
+	include string
+	exclude string
+	field   func(*Filters) *Filter
+}
+
+// filterFlags are the flags which define the global filters.
+var filterFlags = []filterFlag{
+	{
+		include: "filter.metrics.include",
+		exclude: "filter.metrics.exclude",
+		field:   func(f *Filters) *Filter { return &f.Metrics },
+	},
+	{
+		include: "filter.databases.include",
+		exclude: "filter.databases.exclude",
+		field:   func(f *Filters) *Filter { return &f.Databases },
+	},
+	{
+		include: "filter.users.include",
+		exclude: "filter.users.exclude",
+		field:   func(f *Filters) *Filter { return &f.Users },
+	},
+}
+
+func (ff filterFlag) filter(ctx *cli.Context) (Filter, error) {
+	include, err := compilePatterns(ff.include, flagPatterns(ctx, ff.include))
+	if err != nil {
+		return Filter{}, err
+	}
+	exclude, err := compilePatterns(ff.exclude, flagPatterns(ctx, ff.exclude))
+	if err != nil {
+		return Filter{}, err
+	}
+	return Filter{Include: include, Exclude: exclude}, nil
+}
+
+// flagPatterns returns the regular expression of the flag, the flags hold a single expression
+// because splitting them on a separator would break expressions like db_[0-9]{1,3}.
+func flagPatterns(ctx *cli.Context, name string) []string {
+	if pattern := ctx.String(name); pattern != "" {
+		return []string{pattern}
+	}
+	return nil
+}
+
+func applyFlags(ctx *cli.Context, cfg *Config, set func(name string) bool) error {
+	if set("web.listen-address") {
+		cfg.ListenAddress = ctx.String("web.listen-address")
+	}
+	if set("web.telemetry-path") {
+		cfg.TelemetryPath = ctx.String("web.telemetry-path")
+	}
+	if set("web.enable-lifecycle") {
+		cfg.EnableLifecycle = ctx.Bool("web.enable-lifecycle")
+	}
+	if set("web.enable-snapshot") {
+		cfg.EnableSnapshot = ctx.Bool("web.enable-snapshot")
+	}
+	if set("store-timeout") {
+		cfg.StoreTimeout = ctx.Duration("store-timeout")
+	}
+	if set("database-credentials-reload-interval") {
+		cfg.CredentialsReloadInterval = ctx.Duration("database-credentials-reload-interval")
+	}
+	if set("store") {
+		cfg.Store = ctx.String("store")
+	}
+	if set("store-strict-columns") {
+		cfg.StrictColumns = ctx.Bool("store-strict-columns")
+	}
+	if set("store-log-file") {
+		cfg.LogFile = ctx.String("store-log-file")
+	}
+	if set("store-log-stats-period") {
+		cfg.LogStatsPeriod = ctx.Duration("store-log-stats-period")
+	}
+	if set("mappings.file") {
+		cfg.MappingsFile = ctx.String("mappings.file")
+	}
+	if set("queries.file") {
+		cfg.QueriesFile = ctx.String("queries.file")
+	}
+	if set("export-stats") {
+		cfg.ExportStats = ctx.Bool("export-stats")
+	}
+	if set("export-pools") {
+		cfg.ExportPools = ctx.Bool("export-pools")
+	}
+	if set("export-databases") {
+		cfg.ExportDatabases = ctx.Bool("export-databases")
 	}
+	if set("export-lists") {
+		cfg.ExportLists = ctx.Bool("export-lists")
+	}
+	if set("export-totals") {
+		cfg.ExportTotals = ctx.Bool("export-totals")
+	}
+	if set("default-labels") {
+		labels, err := ParseLabels(ctx.String("default-labels"))
+		if err != nil {
+			return fmt.Errorf("invalid default labels: %v", err)
+		}
+		cfg.DefaultLabels = labels
+	}
+	for _, ff := range filterFlags {
+		if !set(ff.include) && !set(ff.exclude) {
+			continue
+		}
+		filter, err := ff.filter(ctx)
+		if err != nil {
+			return err
+		}
+		*ff.field(&cfg.Filters) = filter
+	}
+	if len(cfg.Targets) > 0 && !slices.ContainsFunc(targetFlags, set) {
+		for _, name := range targetOptionFlags {
+			if set(name) {
+				return fmt.Errorf("%v requires one of %v when the targets are defined in the config file", name, strings.Join(targetFlags, ", "))
+			}
+		}
+	}
+	if len(cfg.Targets) == 0 || slices.ContainsFunc(targetFlags, set) {
+		cfg.Targets = []Target{
+			{
+				DatabaseURL:          ctx.String("database-url"),
+				DatabaseURLFile:      ctx.String("database-url-file"),
+				DatabasePasswordFile: ctx.String("database-password-file"),
+				DatabaseSocketDir:    ctx.String("database-socket-dir"),
+				DatabasePort:         ctx.Int("database-port"),
+				DatabaseUser:         ctx.String("database-user"),
+				DatabaseName:         ctx.String("database-name"),
+			},
+		}
+	}
+	return nil
 }
 
+// Stores which can be used to read pgbouncer stats.
+const (
+	// StoreSQL queries the pgbouncer admin console of the targets.
+	StoreSQL = "sql"
+	// StorePgx queries the pgbouncer admin console of the targets using pgx and a persistent connection.
+	StorePgx = "pgx"
+	// StoreLog parses the stats lines of the pgbouncer log.
+	StoreLog = "log"
+)
+
 // Config represents exporter configuration.
 type Config struct {
-	ListenAddress string
-	TelemetryPath string
-	DatabaseURL   string
-	StoreTimeout  time.Duration
-
-	ExportStats     bool
-	ExportPools     bool
-	ExportDatabases bool
-	ExportLists     bool
-	DefaultLabels   string
+	ListenAddress             string
+	TelemetryPath             string
+	EnableLifecycle           bool
+	EnableSnapshot            bool
+	StoreTimeout              time.Duration
+	CredentialsReloadInterval time.Duration
+	Targets                   []Target
+
+	Store          string
+	StrictColumns  bool
+	LogFile        string
+	LogStatsPeriod time.Duration
+
+	MappingsFile string
+	Mappings     []mapping.Mapping
+	QueriesFile  string
+	Queries      []mapping.Query
+
+	ExportStats      bool
+	ExportPools      bool
+	ExportDatabases  bool
+	ExportLists      bool
+	ExportTotals     bool
+	DefaultLabels    map[string]string
+	Labels           map[string]string
+	Filters          Filters
+	CollectorFilters map[string]Filters
+}
+
+// WithTarget returns a copy of the config scoped to the given target,
+// labels of the target are merged into the config labels.
+func (c Config) WithTarget(t Target) Config {
+	labels := maps.Clone(c.Labels)
+	if len(t.Labels) > 0 && labels == nil {
+		labels = make(map[string]string, len(t.Labels))
+	}
+	maps.Copy(labels, t.Labels)
+
+	c.Labels = labels
+	c.Targets = []Target{t}
+	return c
+}
+
+// Validate validates the configuration.
+func (c Config) Validate() error {
+	if c.StoreTimeout <= 0 {
+		return errors.New("store_timeout: must be positive")
+	}
+	if c.CredentialsReloadInterval < 0 {
+		return errors.New("credentials_reload_interval: must not be negative")
+	}
+	if !strings.HasPrefix(c.TelemetryPath, "/") {
+		return fmt.Errorf("web.telemetry_path: must start with /, got %q", c.TelemetryPath)
+	}
+	if err := validateLabels("labels", c.Labels); err != nil {
+		return err
+	}
+	if err := validateLabels("default_labels", c.DefaultLabels); err != nil {
+		return err
+	}
+
+	switch c.Store {
+	case "", StoreSQL, StorePgx:
+	case StoreLog:
+		return c.validateLogStore()
+	default:
+		return fmt.Errorf("store: unknown store %q, must be one of %v, %v, %v", c.Store, StoreSQL, StorePgx, StoreLog)
+	}
+
+	if len(c.Targets) == 0 {
+		return errors.New("targets: at least one target must be configured")
+	}
+
+	names := make(map[string]int, len(c.Targets))
+	labels := make(map[string]int, len(c.Targets))
+
+	for i, t := range c.Targets {
+		if err := t.validate(); err != nil {
+			return fmt.Errorf("targets[%v]: %v", i, err)
+		}
+		if err := validateLabels(fmt.Sprintf("targets[%v].labels", i), t.Labels); err != nil {
+			return err
+		}
+		if len(c.Targets) == 1 {
+			continue
+		}
+
+		if t.Name == "" {
+			return fmt.Errorf("targets[%v].name: must be set when multiple targets are configured", i)
+		}
+		if j, ok := names[t.Name]; ok {
+			return fmt.Errorf("targets[%v].name: duplicate name %q, already used by targets[%v]", i, t.Name, j)
+		}
+		names[t.Name] = i
+
+		// exporters of all targets share the same registry so their labels have to differ
+		key := labelsKey(t.Labels)
+		if j, ok := labels[key]; ok {
+			return fmt.Errorf("targets[%v].labels: must differ from labels of targets[%v]", i, j)
+		}
+		labels[key] = i
+	}
+	return nil
+}
+
+// validateLogStore validates the log store settings, connection settings of the target are not used.
+func (c Config) validateLogStore() error {
+	if c.LogFile == "" {
+		return errors.New("store_log_file: must be set when using the log store")
+	}
+	if c.LogStatsPeriod < time.Second {
+		return fmt.Errorf("store_log_stats_period: must be at least 1s, got %v", c.LogStatsPeriod)
+	}
+	if len(c.Targets) > 1 {
+		return errors.New("targets: multiple targets are not supported by the log store")
+	}
+	if c.QueriesFile != "" {
+		return errors.New("queries_file: custom queries are not supported by the log store")
+	}
+	for i, t := range c.Targets {
+		if err := validateLabels(fmt.Sprintf("targets[%v].labels", i), t.Labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func labelsKey(labels map[string]string) string {
+	var sb strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		sb.WriteString(name)
+		sb.WriteByte('=')
+		sb.WriteString(labels[name])
+		sb.WriteByte(0)
+	}
+	return sb.String()
 }
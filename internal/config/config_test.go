@@ -0,0 +1,279 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func validConfig(targets ...Target) Config {
+	return Config{
+		TelemetryPath: "/metrics",
+		StoreTimeout:  time.Second,
+		Targets:       targets,
+	}
+}
+
+var (
+	validateCases = []struct {
+		name string
+		cfg  Config
+		err  string
+	}{
+		{
+			name: "valid",
+			cfg:  validConfig(Target{DatabaseURL: "postgres://localhost"}),
+		},
+		{
+			name: "valid multiple targets",
+			cfg: validConfig(
+				Target{Name: "a", DatabaseURL: "postgres://a", Labels: map[string]string{"instance": "a"}},
+				Target{Name: "b", DatabaseURL: "postgres://b", Labels: map[string]string{"instance": "b"}},
+			),
+		},
+		{
+			name: "no targets",
+			cfg:  validConfig(),
+			err:  "targets: at least one target must be configured",
+		},
+		{
+			name: "invalid store timeout",
+			cfg: Config{
+				TelemetryPath: "/metrics",
+				Targets:       []Target{{DatabaseURL: "postgres://localhost"}},
+			},
+			err: "store_timeout: must be positive",
+		},
+		{
+			name: "invalid telemetry path",
+			cfg: Config{
+				TelemetryPath: "metrics",
+				StoreTimeout:  time.Second,
+				Targets:       []Target{{DatabaseURL: "postgres://localhost"}},
+			},
+			err: `web.telemetry_path: must start with /, got "metrics"`,
+		},
+		{
+			name: "invalid label name",
+			cfg: Config{
+				TelemetryPath: "/metrics",
+				StoreTimeout:  time.Second,
+				Labels:        map[string]string{"my-env": "dev"},
+				Targets:       []Target{{DatabaseURL: "postgres://localhost"}},
+			},
+			err: `labels.my-env: invalid label name "my-env", must match [a-zA-Z_][a-zA-Z0-9_]*`,
+		},
+		{
+			name: "invalid target label name",
+			cfg:  validConfig(Target{DatabaseURL: "postgres://localhost", Labels: map[string]string{"__x": "a"}}),
+			err:  `targets[0].labels.__x: label name "__x" is reserved for internal use`,
+		},
+		{
+			name: "invalid target",
+			cfg:  validConfig(Target{DatabaseURL: "postgres://localhost", DatabaseSocketDir: "/tmp"}),
+			err:  "targets[0]: database url and database socket dir are mutually exclusive",
+		},
+		{
+			name: "missing target name",
+			cfg: validConfig(
+				Target{Name: "a", DatabaseURL: "postgres://a", Labels: map[string]string{"instance": "a"}},
+				Target{DatabaseURL: "postgres://b", Labels: map[string]string{"instance": "b"}},
+			),
+			err: "targets[1].name: must be set when multiple targets are configured",
+		},
+		{
+			name: "duplicate target name",
+			cfg: validConfig(
+				Target{Name: "a", DatabaseURL: "postgres://a", Labels: map[string]string{"instance": "a"}},
+				Target{Name: "a", DatabaseURL: "postgres://b", Labels: map[string]string{"instance": "b"}},
+			),
+			err: `targets[1].name: duplicate name "a", already used by targets[0]`,
+		},
+		{
+			name: "valid log store",
+			cfg: Config{
+				TelemetryPath:  "/metrics",
+				StoreTimeout:   time.Second,
+				Store:          StoreLog,
+				LogFile:        "-",
+				LogStatsPeriod: time.Minute,
+				Targets:        []Target{{}},
+			},
+		},
+		{
+			name: "unknown store",
+			cfg: Config{
+				TelemetryPath: "/metrics",
+				StoreTimeout:  time.Second,
+				Store:         "file",
+				Targets:       []Target{{DatabaseURL: "postgres://localhost"}},
+			},
+			err: `store: unknown store "file", must be one of sql, pgx, log`,
+		},
+		{
+			name: "missing log file",
+			cfg: Config{
+				TelemetryPath:  "/metrics",
+				StoreTimeout:   time.Second,
+				Store:          StoreLog,
+				LogStatsPeriod: time.Minute,
+			},
+			err: "store_log_file: must be set when using the log store",
+		},
+		{
+			name: "invalid log stats period",
+			cfg: Config{
+				TelemetryPath:  "/metrics",
+				StoreTimeout:   time.Second,
+				Store:          StoreLog,
+				LogFile:        "-",
+				LogStatsPeriod: time.Millisecond,
+			},
+			err: "store_log_stats_period: must be at least 1s, got 1ms",
+		},
+		{
+			name: "log store with multiple targets",
+			cfg: Config{
+				TelemetryPath:  "/metrics",
+				StoreTimeout:   time.Second,
+				Store:          StoreLog,
+				LogFile:        "-",
+				LogStatsPeriod: time.Minute,
+				Targets:        []Target{{Name: "a"}, {Name: "b"}},
+			},
+			err: "targets: multiple targets are not supported by the log store",
+		},
+		{
+			name: "log store with queries",
+			cfg: Config{
+				TelemetryPath:  "/metrics",
+				StoreTimeout:   time.Second,
+				Store:          StoreLog,
+				LogFile:        "-",
+				LogStatsPeriod: time.Minute,
+				QueriesFile:    "queries.yml",
+			},
+			err: "queries_file: custom queries are not supported by the log store",
+		},
+		{
+			name: "duplicate target labels",
+			cfg: validConfig(
+				Target{Name: "a", DatabaseURL: "postgres://a", Labels: map[string]string{"instance": "a"}},
+				Target{Name: "b", DatabaseURL: "postgres://b", Labels: map[string]string{"instance": "a"}},
+			),
+			err: "targets[1].labels: must differ from labels of targets[0]",
+		},
+	}
+)
+
+func TestValidate(t *testing.T) {
+	for _, cs := range validateCases {
+		t.Run(cs.name, func(t *testing.T) {
+			err := cs.cfg.Validate()
+			if cs.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, cs.err)
+		})
+	}
+}
+
+func TestWithTarget(t *testing.T) {
+	cfg := validConfig(
+		Target{Name: "a", Labels: map[string]string{"instance": "a"}},
+		Target{Name: "b", Labels: map[string]string{"instance": "b", "env": "dev"}},
+	)
+	cfg.Labels = map[string]string{"env": "prod"}
+
+	scoped := cfg.WithTarget(cfg.Targets[1])
+	require.Equal(t, []Target{cfg.Targets[1]}, scoped.Targets)
+	require.Equal(t, map[string]string{"instance": "b", "env": "dev"}, scoped.Labels)
+	require.Equal(t, map[string]string{"env": "prod"}, cfg.Labels)
+}
+
+func TestLoadFromCLI(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "config.yml", testConfigFile)
+
+	load := func(args ...string) (Config, error) {
+		var cfg Config
+		app := &cli.App{
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "config.file"},
+				&cli.StringFlag{Name: "web.listen-address", Value: ":9127"},
+				&cli.StringFlag{Name: "web.telemetry-path", Value: "/metrics"},
+				&cli.StringFlag{Name: "database-url"},
+				&cli.StringFlag{Name: "database-password-file"},
+				&cli.StringFlag{Name: "database-user"},
+				&cli.DurationFlag{Name: "store-timeout", Value: time.Second * 2},
+				&cli.BoolFlag{Name: "export-stats", Value: true},
+				&cli.BoolFlag{Name: "export-pools", Value: true},
+				&cli.StringFlag{Name: "filter.databases.exclude", EnvVars: []string{"FILTER_DATABASES_EXCLUDE"}},
+				&cli.StringFlag{Name: "mappings.file"},
+				&cli.StringFlag{Name: "queries.file"},
+			},
+			Action: func(ctx *cli.Context) error {
+				var err error
+				cfg, err = LoadFromCLI(ctx)
+				return err
+			},
+		}
+		err := app.Run(append([]string{"app"}, args...))
+		return cfg, err
+	}
+	run := func(args ...string) Config {
+		cfg, err := load(args...)
+		require.NoError(t, err)
+		return cfg
+	}
+
+	cfg := run("--database-url", "postgres://localhost")
+	require.Equal(t, ":9127", cfg.ListenAddress)
+	require.Equal(t, []Target{{DatabaseURL: "postgres://localhost"}}, cfg.Targets)
+
+	cfg = run("--config.file", path)
+	require.Equal(t, ":9200", cfg.ListenAddress)
+	require.Equal(t, 5*time.Second, cfg.StoreTimeout)
+	require.False(t, cfg.ExportStats)
+	require.True(t, cfg.ExportPools)
+	require.Len(t, cfg.Targets, 2)
+
+	require.True(t, cfg.Filters.Databases.IsEmpty())
+
+	cfg = run("--config.file", path, "--web.listen-address", ":9300", "--export-stats", "--database-url", "postgres://other", "--filter.databases.exclude", "test_.*")
+	require.Equal(t, ":9300", cfg.ListenAddress)
+	require.Equal(t, 5*time.Second, cfg.StoreTimeout)
+	require.True(t, cfg.ExportStats)
+	require.Equal(t, []Target{{DatabaseURL: "postgres://other"}}, cfg.Targets)
+	require.Equal(t, map[string]string{"env": "prod"}, cfg.Labels)
+	require.False(t, cfg.Filters.Databases.Match("test_1"))
+	require.False(t, cfg.Filters.Metrics.Match("pgbouncer_exporter_stats_total_sent"))
+	require.Contains(t, cfg.CollectorFilters, CollectorPools)
+	require.Nil(t, cfg.Mappings)
+
+	// the target option flags can not be applied to the targets from the file
+	_, err := load("--config.file", path, "--database-password-file", "/run/secrets/other")
+	require.EqualError(t, err, "database-password-file requires one of database-url, database-url-file, database-socket-dir when the targets are defined in the config file")
+
+	cfg = run("--config.file", path, "--database-url", "postgres://other", "--database-password-file", "/run/secrets/other")
+	require.Equal(t, []Target{{DatabaseURL: "postgres://other", DatabasePasswordFile: "/run/secrets/other"}}, cfg.Targets)
+
+	mappingsPath := writeFile(t, t.TempDir(), "mappings.yml", "mappings:\n  - command: SHOW POOLS\n    column: sv_new\n    metric: pools_new_server\n")
+	cfg = run("--database-url", "postgres://localhost", "--mappings.file", mappingsPath)
+	require.Equal(t, mappingsPath, cfg.MappingsFile)
+	require.Len(t, cfg.Mappings, 1)
+	require.Equal(t, "pools_new_server", cfg.Mappings[0].Metric)
+
+	t.Setenv("FILTER_DATABASES_EXCLUDE", "db_[0-9]{1,3}")
+	cfg = run("--database-url", "postgres://localhost")
+	require.False(t, cfg.Filters.Databases.Match("db_123"))
+	require.True(t, cfg.Filters.Databases.Match("db_1234"))
+
+	queriesPath := writeFile(t, t.TempDir(), "queries.yml", "queries:\n  - command: SHOW STATS_TOTALS\n    metrics:\n      - column: total_xact_count\n        metric: stats_totals_xact_count\n")
+	cfg = run("--database-url", "postgres://localhost", "--queries.file", queriesPath)
+	require.Equal(t, queriesPath, cfg.QueriesFile)
+	require.Len(t, cfg.Queries, 1)
+	require.Equal(t, "SHOW STATS_TOTALS", cfg.Queries[0].Command)
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig represents the YAML config file, pointers are used to tell unset values from zero values.
+type fileConfig struct {
+	Web struct {
+		ListenAddress   *string `yaml:"listen_address"`
+		TelemetryPath   *string `yaml:"telemetry_path"`
+		EnableLifecycle *bool   `yaml:"enable_lifecycle"`
+		EnableSnapshot  *bool   `yaml:"enable_snapshot"`
+	} `yaml:"web"`
+	StoreTimeout              *time.Duration `yaml:"store_timeout"`
+	CredentialsReloadInterval *time.Duration `yaml:"credentials_reload_interval"`
+	Collectors                struct {
+		Stats     *bool `yaml:"stats"`
+		Pools     *bool `yaml:"pools"`
+		Databases *bool `yaml:"databases"`
+		Lists     *bool `yaml:"lists"`
+		Totals    *bool `yaml:"totals"`
+	} `yaml:"collectors"`
+	Labels  map[string]string `yaml:"labels"`
+	Filters struct {
+		fileFilters `yaml:",inline"`
+		Collectors  map[string]fileFilters `yaml:"collectors"`
+	} `yaml:"filters"`
+	Targets []fileTarget `yaml:"targets"`
+}
+
+type fileFilters struct {
+	Metrics   fileFilter `yaml:"metrics"`
+	Databases fileFilter `yaml:"databases"`
+	Users     fileFilter `yaml:"users"`
+}
+
+type fileFilter struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+type fileTarget struct {
+	Name    string `yaml:"name"`
+	URL     string `yaml:"url"`
+	URLFile string `yaml:"url_file"`
+	Socket  struct {
+		Dir      string `yaml:"dir"`
+		Port     *int   `yaml:"port"`
+		Database string `yaml:"database"`
+	} `yaml:"socket"`
+	Auth struct {
+		User         string `yaml:"user"`
+		PasswordFile string `yaml:"password_file"`
+	} `yaml:"auth"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+const (
+	defaultSocketPort     = 6432
+	defaultSocketDatabase = "pgbouncer"
+)
+
+func readFile(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %v", err)
+	}
+
+	file, err := parseFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse config file %v: %v", path, err)
+	}
+	return file, nil
+}
+
+func parseFile(data []byte) (*fileConfig, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	file := new(fileConfig)
+	if err := dec.Decode(file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (f *fileConfig) apply(cfg *Config) error {
+	if f.Web.ListenAddress != nil {
+		cfg.ListenAddress = *f.Web.ListenAddress
+	}
+	if f.Web.TelemetryPath != nil {
+		cfg.TelemetryPath = *f.Web.TelemetryPath
+	}
+	if f.Web.EnableLifecycle != nil {
+		cfg.EnableLifecycle = *f.Web.EnableLifecycle
+	}
+	if f.Web.EnableSnapshot != nil {
+		cfg.EnableSnapshot = *f.Web.EnableSnapshot
+	}
+	if f.StoreTimeout != nil {
+		cfg.StoreTimeout = *f.StoreTimeout
+	}
+	if f.CredentialsReloadInterval != nil {
+		cfg.CredentialsReloadInterval = *f.CredentialsReloadInterval
+	}
+	if f.Collectors.Stats != nil {
+		cfg.ExportStats = *f.Collectors.Stats
+	}
+	if f.Collectors.Pools != nil {
+		cfg.ExportPools = *f.Collectors.Pools
+	}
+	if f.Collectors.Databases != nil {
+		cfg.ExportDatabases = *f.Collectors.Databases
+	}
+	if f.Collectors.Lists != nil {
+		cfg.ExportLists = *f.Collectors.Lists
+	}
+	if f.Collectors.Totals != nil {
+		cfg.ExportTotals = *f.Collectors.Totals
+	}
+	if f.Labels != nil {
+		cfg.Labels = f.Labels
+	}
+
+	filters, err := f.Filters.filters("filters")
+	if err != nil {
+		return err
+	}
+	cfg.Filters = filters
+
+	if len(f.Filters.Collectors) > 0 {
+		cfg.CollectorFilters = make(map[string]Filters, len(f.Filters.Collectors))
+	}
+	for _, name := range slices.Sorted(maps.Keys(f.Filters.Collectors)) {
+		ff := f.Filters.Collectors[name]
+		key := "filters.collectors." + name
+		if !slices.Contains(collectorNames, name) {
+			return fmt.Errorf("%v: unknown collector, must be one of %v", key, strings.Join(collectorNames, ", "))
+		}
+		filters, err := ff.filters(key)
+		if err != nil {
+			return err
+		}
+		cfg.CollectorFilters[name] = filters
+	}
+
+	if len(f.Targets) == 0 {
+		return nil
+	}
+
+	targets := make([]Target, 0, len(f.Targets))
+	for i, ft := range f.Targets {
+		t, err := ft.target()
+		if err != nil {
+			return fmt.Errorf("targets[%v].%v", i, err)
+		}
+		targets = append(targets, t)
+	}
+	cfg.Targets = targets
+	return nil
+}
+
+func (f fileFilters) filters(key string) (Filters, error) {
+	metrics, err := f.Metrics.filter(key + ".metrics")
+	if err != nil {
+		return Filters{}, err
+	}
+	databases, err := f.Databases.filter(key + ".databases")
+	if err != nil {
+		return Filters{}, err
+	}
+	users, err := f.Users.filter(key + ".users")
+	if err != nil {
+		return Filters{}, err
+	}
+	return Filters{Metrics: metrics, Databases: databases, Users: users}, nil
+}
+
+func (f fileFilter) filter(key string) (Filter, error) {
+	include, err := compilePatterns(key+".include", f.Include)
+	if err != nil {
+		return Filter{}, err
+	}
+	exclude, err := compilePatterns(key+".exclude", f.Exclude)
+	if err != nil {
+		return Filter{}, err
+	}
+	return Filter{Include: include, Exclude: exclude}, nil
+}
+
+func (ft fileTarget) target() (Target, error) {
+	if ft.Socket.Dir == "" {
+		switch {
+		case ft.Socket.Port != nil:
+			return Target{}, errors.New("socket.port: requires socket.dir to be set")
+		case ft.Socket.Database != "":
+			return Target{}, errors.New("socket.database: requires socket.dir to be set")
+		case ft.Auth.User != "":
+			return Target{}, errors.New("auth.user: only supported with socket.dir, set the user in the url instead")
+		}
+	}
+
+	t := Target{
+		Name:                 ft.Name,
+		DatabaseURL:          ft.URL,
+		DatabaseURLFile:      ft.URLFile,
+		DatabasePasswordFile: ft.Auth.PasswordFile,
+		DatabaseSocketDir:    ft.Socket.Dir,
+		DatabasePort:         defaultSocketPort,
+		DatabaseUser:         ft.Auth.User,
+		DatabaseName:         ft.Socket.Database,
+		Labels:               ft.Labels,
+	}
+	if ft.Socket.Port != nil {
+		t.DatabasePort = *ft.Socket.Port
+	}
+	if t.DatabaseName == "" {
+		t.DatabaseName = defaultSocketDatabase
+	}
+	return t, nil
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testConfigFile = `
+web:
+  listen_address: ":9200"
+  telemetry_path: /pgbouncer/metrics
+  enable_lifecycle: true
+  enable_snapshot: true
+store_timeout: 5s
+credentials_reload_interval: 1m
+collectors:
+  stats: false
+  lists: false
+  totals: false
+labels:
+  env: prod
+filters:
+  metrics:
+    exclude:
+      - pgbouncer_exporter_stats_.*
+  collectors:
+    pools:
+      databases:
+        exclude:
+          - test_.*
+targets:
+  - name: main
+    url: postgres://pgbouncer@localhost:6432/pgbouncer
+    auth:
+      password_file: /run/secrets/pgbouncer
+    labels:
+      instance: main
+  - name: local
+    socket:
+      dir: /var/run/pgbouncer
+      port: 6433
+    auth:
+      user: postgres
+    labels:
+      instance: local
+`
+
+func TestParseFile(t *testing.T) {
+	file, err := parseFile([]byte(testConfigFile))
+	require.NoError(t, err)
+
+	cfg := Config{
+		ExportStats:     true,
+		ExportPools:     true,
+		ExportDatabases: true,
+		ExportLists:     true,
+		ExportTotals:    true,
+	}
+	require.NoError(t, file.apply(&cfg))
+	require.NoError(t, cfg.Validate())
+
+	require.Equal(t, ":9200", cfg.ListenAddress)
+	require.Equal(t, "/pgbouncer/metrics", cfg.TelemetryPath)
+	require.True(t, cfg.EnableLifecycle)
+	require.True(t, cfg.EnableSnapshot)
+	require.Equal(t, 5*time.Second, cfg.StoreTimeout)
+	require.Equal(t, time.Minute, cfg.CredentialsReloadInterval)
+	require.False(t, cfg.ExportStats)
+	require.True(t, cfg.ExportPools)
+	require.True(t, cfg.ExportDatabases)
+	require.False(t, cfg.ExportLists)
+	require.False(t, cfg.ExportTotals)
+	require.Equal(t, map[string]string{"env": "prod"}, cfg.Labels)
+	require.False(t, cfg.Filters.Metrics.Match("pgbouncer_exporter_stats_total_sent"))
+	require.True(t, cfg.Filters.Metrics.Match("pgbouncer_exporter_pools_active_clients"))
+	require.True(t, cfg.Filters.Databases.IsEmpty())
+	require.Len(t, cfg.CollectorFilters, 1)
+	require.False(t, cfg.CollectorFilters[CollectorPools].Databases.Match("test_1"))
+	require.True(t, cfg.CollectorFilters[CollectorPools].Databases.Match("main"))
+	require.Equal(t, []Target{
+		{
+			Name:                 "main",
+			DatabaseURL:          "postgres://pgbouncer@localhost:6432/pgbouncer",
+			DatabasePasswordFile: "/run/secrets/pgbouncer",
+			DatabasePort:         defaultSocketPort,
+			DatabaseName:         defaultSocketDatabase,
+			Labels:               map[string]string{"instance": "main"},
+		},
+		{
+			Name:              "local",
+			DatabaseSocketDir: "/var/run/pgbouncer",
+			DatabasePort:      6433,
+			DatabaseUser:      "postgres",
+			DatabaseName:      defaultSocketDatabase,
+			Labels:            map[string]string{"instance": "local"},
+		},
+	}, cfg.Targets)
+}
+
+func TestParseFileEmpty(t *testing.T) {
+	file, err := parseFile(nil)
+	require.NoError(t, err)
+
+	cfg := Config{Targets: []Target{{DatabaseURL: "postgres://localhost"}}}
+	require.NoError(t, file.apply(&cfg))
+	require.Len(t, cfg.Targets, 1)
+	require.True(t, cfg.Filters.Metrics.IsEmpty())
+	require.Nil(t, cfg.CollectorFilters)
+}
+
+var (
+	fileErrorCases = []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "unknown key",
+			data: "store_timeout: 1s\ncolectors:\n  stats: true\n",
+			err:  "line 2: field colectors not found",
+		},
+		{
+			name: "invalid duration",
+			data: "store_timeout: fast\n",
+			err:  "line 1: cannot unmarshal !!str `fast` into time.Duration",
+		},
+		{
+			name: "invalid metrics include",
+			data: "filters:\n  metrics:\n    include: ['pgbouncer_(']\n",
+			err:  "filters.metrics.include[0]: error parsing regexp",
+		},
+		{
+			name: "invalid users exclude",
+			data: "filters:\n  users:\n    exclude: ['.*', '[']\n",
+			err:  "filters.users.exclude[1]: error parsing regexp",
+		},
+		{
+			name: "invalid collector databases include",
+			data: "filters:\n  collectors:\n    stats:\n      databases:\n        include: ['(']\n",
+			err:  "filters.collectors.stats.databases.include[0]: error parsing regexp",
+		},
+		{
+			name: "unknown collector",
+			data: "filters:\n  collectors:\n    pool:\n      databases:\n        include: [main]\n",
+			err:  "filters.collectors.pool: unknown collector, must be one of stats, pools, databases, lists, totals",
+		},
+		{
+			name: "socket port without dir",
+			data: "targets:\n  - url: postgres://localhost\n  - url: postgres://remote\n    socket:\n      port: 6432\n",
+			err:  "targets[1].socket.port: requires socket.dir to be set",
+		},
+		{
+			name: "auth user without socket",
+			data: "targets:\n  - url: postgres://localhost\n    auth:\n      user: postgres\n",
+			err:  "targets[0].auth.user: only supported with socket.dir",
+		},
+	}
+)
+
+func TestParseFileErrors(t *testing.T) {
+	for _, cs := range fileErrorCases {
+		t.Run(cs.name, func(t *testing.T) {
+			file, err := parseFile([]byte(cs.data))
+			if err == nil {
+				err = file.apply(&Config{})
+			}
+			require.ErrorContains(t, err, cs.err)
+		})
+	}
+}
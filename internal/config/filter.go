@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Names of the collectors used as keys of Config.CollectorFilters.
+const (
+	CollectorStats     = "stats"
+	CollectorPools     = "pools"
+	CollectorDatabases = "databases"
+	CollectorLists     = "lists"
+	CollectorTotals    = "totals"
+)
+
+// CollectorQueries is the name selecting the custom queries per scrape,
+// it has no collector filters.
+const CollectorQueries = "queries"
+
+var collectorNames = []string{
+	CollectorStats,
+	CollectorPools,
+	CollectorDatabases,
+	CollectorLists,
+	CollectorTotals,
+}
+
+// Filters represents filters of metric names and of values of the database and user labels.
+type Filters struct {
+	Metrics   Filter
+	Databases Filter
+	Users     Filter
+}
+
+// Filter matches values against include and exclude regular expressions.
+// The expressions are anchored, so they have to match the whole value.
+type Filter struct {
+	Include []*regexp.Regexp
+	Exclude []*regexp.Regexp
+}
+
+// Match reports whether value matches any of the include expressions, or there are none,
+// and does not match any of the exclude expressions.
+func (f Filter) Match(value string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, value) {
+		return false
+	}
+	return !matchAny(f.Exclude, value)
+}
+
+// IsEmpty reports whether the filter has no expressions and thus matches everything.
+func (f Filter) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+func matchAny(exprs []*regexp.Regexp, value string) bool {
+	for _, expr := range exprs {
+		if expr.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compilePatterns(key string, patterns []string) ([]*regexp.Regexp, error) {
+	exprs := make([]*regexp.Regexp, 0, len(patterns))
+	for i, pattern := range patterns {
+		expr, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%v[%v]: %v", key, i, err)
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
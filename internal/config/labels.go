@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ParseLabels parses labels in the name=value format separated by commas or whitespace.
+// Values containing separators can be quoted using double or single quotes, backslash escapes
+// the next character inside quotes. Example: instance=pg1, env="dev 2" team='a\'b'.
+func ParseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	p := labelParser{s: s}
+
+	for {
+		p.skipSeparators()
+		if p.done() {
+			break
+		}
+
+		name, value, err := p.label()
+		if err != nil {
+			return nil, err
+		}
+		if err := validateLabelName(name); err != nil {
+			return nil, err
+		}
+		if _, ok := labels[name]; ok {
+			return nil, fmt.Errorf("duplicate label %q", name)
+		}
+		labels[name] = value
+	}
+
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	return labels, nil
+}
+
+type labelParser struct {
+	s   string
+	pos int
+}
+
+func (p *labelParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *labelParser) skipSeparators() {
+	for !p.done() && isLabelSeparator(p.s[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *labelParser) label() (string, string, error) {
+	start := p.pos
+	for !p.done() && p.s[p.pos] != '=' && !isLabelSeparator(p.s[p.pos]) {
+		p.pos++
+	}
+	name := p.s[start:p.pos]
+
+	if p.done() || p.s[p.pos] != '=' {
+		return "", "", fmt.Errorf("invalid label %q, expected name=value", name)
+	}
+	p.pos++ // skip =
+
+	if !p.done() && (p.s[p.pos] == '"' || p.s[p.pos] == '\'') {
+		value, err := p.quoted()
+		if err != nil {
+			return "", "", fmt.Errorf("invalid value of label %q: %v", name, err)
+		}
+		if !p.done() && !isLabelSeparator(p.s[p.pos]) {
+			return "", "", fmt.Errorf("invalid value of label %q: unexpected %q after closing quote", name, p.s[p.pos])
+		}
+		return name, value, nil
+	}
+
+	start = p.pos
+	for !p.done() && !isLabelSeparator(p.s[p.pos]) {
+		if c := p.s[p.pos]; c == '"' || c == '\'' || c == '=' {
+			return "", "", fmt.Errorf("invalid value of label %q: unexpected %q, quote the value", name, c)
+		}
+		p.pos++
+	}
+	return name, p.s[start:p.pos], nil
+}
+
+func (p *labelParser) quoted() (string, error) {
+	quote := p.s[p.pos]
+	p.pos++ // skip opening quote
+
+	var sb strings.Builder
+	for !p.done() {
+		c := p.s[p.pos]
+		p.pos++
+
+		switch c {
+		case quote:
+			return sb.String(), nil
+		case '\\':
+			if p.done() {
+				return "", fmt.Errorf("unterminated quoted value")
+			}
+			sb.WriteByte(p.s[p.pos])
+			p.pos++
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated quoted value")
+}
+
+func isLabelSeparator(c byte) bool {
+	return c == ',' || c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// validateLabelName validates name according to the prometheus data model.
+func validateLabelName(name string) error {
+	if name == "" {
+		return fmt.Errorf("label name must not be empty")
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9' && i > 0) {
+			continue
+		}
+		return fmt.Errorf("invalid label name %q, must match [a-zA-Z_][a-zA-Z0-9_]*", name)
+	}
+	if strings.HasPrefix(name, "__") {
+		return fmt.Errorf("label name %q is reserved for internal use", name)
+	}
+	return nil
+}
+
+func validateLabels(key string, labels map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		if err := validateLabelName(name); err != nil {
+			return fmt.Errorf("%v.%v: %v", key, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	parseLabelsCases = []struct {
+		name     string
+		value    string
+		expected map[string]string
+		err      string
+	}{
+		{
+			name:     "empty",
+			value:    "",
+			expected: nil,
+		},
+		{
+			name:     "only separators",
+			value:    " , ",
+			expected: nil,
+		},
+		{
+			name:  "blank value",
+			value: "key=",
+			expected: map[string]string{
+				"key": "",
+			},
+		},
+		{
+			name:  "single item",
+			value: "key=value",
+			expected: map[string]string{
+				"key": "value",
+			},
+		},
+		{
+			name:  "space separated",
+			value: "key=value key2=value2",
+			expected: map[string]string{
+				"key":  "value",
+				"key2": "value2",
+			},
+		},
+		{
+			name:  "comma separated",
+			value: "key=value,key2=value2, key3=value3",
+			expected: map[string]string{
+				"key":  "value",
+				"key2": "value2",
+				"key3": "value3",
+			},
+		},
+		{
+			name:  "trailing separators",
+			value: " key=value key2=value2 ,",
+			expected: map[string]string{
+				"key":  "value",
+				"key2": "value2",
+			},
+		},
+		{
+			name:  "double quoted value",
+			value: `env="dev 2, eu" team=db`,
+			expected: map[string]string{
+				"env":  "dev 2, eu",
+				"team": "db",
+			},
+		},
+		{
+			name:  "single quoted value",
+			value: `env='dev "2"'`,
+			expected: map[string]string{
+				"env": `dev "2"`,
+			},
+		},
+		{
+			name:  "escaped quote",
+			value: `env="dev \"2\"" path='C:\\data'`,
+			expected: map[string]string{
+				"env":  `dev "2"`,
+				"path": `C:\data`,
+			},
+		},
+		{
+			name:  "empty quoted value",
+			value: `env=""`,
+			expected: map[string]string{
+				"env": "",
+			},
+		},
+		{
+			name:  "value with equal sign in quotes",
+			value: `query="a=b"`,
+			expected: map[string]string{
+				"query": "a=b",
+			},
+		},
+		{
+			name:  "missing equal sign",
+			value: "key",
+			err:   `invalid label "key", expected name=value`,
+		},
+		{
+			name:  "colon instead of equal sign",
+			value: "instance=pg1 env:dev",
+			err:   `invalid label "env:dev", expected name=value`,
+		},
+		{
+			name:  "empty name",
+			value: "=value",
+			err:   "label name must not be empty",
+		},
+		{
+			name:  "invalid name",
+			value: "my-env=dev",
+			err:   `invalid label name "my-env", must match [a-zA-Z_][a-zA-Z0-9_]*`,
+		},
+		{
+			name:  "name starting with digit",
+			value: "1env=dev",
+			err:   `invalid label name "1env", must match [a-zA-Z_][a-zA-Z0-9_]*`,
+		},
+		{
+			name:  "reserved name",
+			value: "__name__=dev",
+			err:   `label name "__name__" is reserved for internal use`,
+		},
+		{
+			name:  "duplicate name",
+			value: "env=dev env=prod",
+			err:   `duplicate label "env"`,
+		},
+		{
+			name:  "unterminated quote",
+			value: `env="dev`,
+			err:   `invalid value of label "env": unterminated quoted value`,
+		},
+		{
+			name:  "text after closing quote",
+			value: `env="dev"x`,
+			err:   `invalid value of label "env": unexpected 'x' after closing quote`,
+		},
+		{
+			name:  "unquoted quote",
+			value: `env=dev"x`,
+			err:   `invalid value of label "env": unexpected '"', quote the value`,
+		},
+	}
+)
+
+func TestParseLabels(t *testing.T) {
+	for _, cs := range parseLabelsCases {
+		t.Run(cs.name, func(t *testing.T) {
+			labels, err := ParseLabels(cs.value)
+			if cs.err != "" {
+				require.EqualError(t, err, cs.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, cs.expected, labels)
+		})
+	}
+}
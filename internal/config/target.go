@@ -0,0 +1,172 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Target represents a single pgbouncer instance the exporter connects to.
+type Target struct {
+	Name                 string
+	DatabaseURL          string
+	DatabaseURLFile      string
+	DatabasePasswordFile string
+	DatabaseSocketDir    string
+	DatabasePort         int
+	DatabaseUser         string
+	DatabaseName         string
+	Labels               map[string]string
+}
+
+// String returns the name of the target used in logs.
+func (t Target) String() string {
+	if t.Name == "" {
+		return "default"
+	}
+	return t.Name
+}
+
+// DataSourceName returns the data source name used to connect to the pgbouncer admin console.
+// When DatabaseSocketDir is set the connection is made over a unix socket, otherwise DatabaseURL
+// or the contents of DatabaseURLFile are used. The contents of DatabasePasswordFile, if set, are used as password.
+// Files are read on every call so that rotated credentials are picked up.
+func (t Target) DataSourceName() (string, error) {
+	dsn, err := t.baseDataSourceName()
+	if err != nil {
+		return "", err
+	}
+	if t.DatabasePasswordFile == "" {
+		return dsn, nil
+	}
+
+	password, err := readSecretFile(t.DatabasePasswordFile)
+	if err != nil {
+		return "", fmt.Errorf("could not read database password file: %v", err)
+	}
+	return setDSNPassword(dsn, password)
+}
+
+// WatchesCredentials reports whether credentials are read from files that may be rotated.
+func (t Target) WatchesCredentials() bool {
+	return t.DatabaseURLFile != "" || t.DatabasePasswordFile != ""
+}
+
+func (t Target) baseDataSourceName() (string, error) {
+	if err := t.validate(); err != nil {
+		return "", err
+	}
+
+	switch {
+	case t.DatabaseURL != "":
+		return t.DatabaseURL, nil
+	case t.DatabaseURLFile != "":
+		dsn, err := readSecretFile(t.DatabaseURLFile)
+		if err != nil {
+			return "", fmt.Errorf("could not read database url file: %v", err)
+		}
+		if dsn == "" {
+			return "", fmt.Errorf("database url file %v is empty", t.DatabaseURLFile)
+		}
+		return dsn, nil
+	default:
+		return buildSocketDSN(t.DatabaseSocketDir, t.DatabasePort, t.DatabaseUser, t.DatabaseName)
+	}
+}
+
+// validate validates the target without reading any of the referenced files.
+func (t Target) validate() error {
+	var sources []string
+	if t.DatabaseURL != "" {
+		sources = append(sources, "database url")
+	}
+	if t.DatabaseURLFile != "" {
+		sources = append(sources, "database url file")
+	}
+	if t.DatabaseSocketDir != "" {
+		sources = append(sources, "database socket dir")
+	}
+
+	switch len(sources) {
+	case 0:
+		return errors.New("either database url, database url file or database socket dir must be set")
+	case 1:
+	default:
+		return fmt.Errorf("%v are mutually exclusive", strings.Join(sources, " and "))
+	}
+
+	if t.DatabaseSocketDir != "" {
+		return validateSocket(t.DatabaseSocketDir, t.DatabasePort, t.DatabaseName)
+	}
+	return nil
+}
+
+func readSecretFile(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// setDSNPassword sets the password of dsn which can be either in url or key/value format.
+func setDSNPassword(dsn string, password string) (string, error) {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		// later keys take precedence over the earlier ones
+		return dsn + " password=" + quoteDSNValue(password), nil
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", fmt.Errorf("could not parse database url: %v", err)
+	}
+	var username string
+	if u.User != nil {
+		username = u.User.Username()
+	}
+	u.User = url.UserPassword(username, password)
+	return u.String(), nil
+}
+
+func validateSocket(dir string, port int, name string) error {
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("database socket dir must be an absolute path, got %q", dir)
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("database port must be between 1 and 65535, got %v", port)
+	}
+	if name == "" {
+		return errors.New("database name must be set")
+	}
+	return nil
+}
+
+func buildSocketDSN(dir string, port int, user string, name string) (string, error) {
+	if err := validateSocket(dir, port, name); err != nil {
+		return "", err
+	}
+
+	params := []string{
+		"host=" + quoteDSNValue(filepath.Clean(dir)),
+		"port=" + strconv.Itoa(port),
+		"dbname=" + quoteDSNValue(name),
+	}
+	// with peer authentication the user defaults to the name of the operating system user
+	if user != "" {
+		params = append(params, "user="+quoteDSNValue(user))
+	}
+	return strings.Join(params, " "), nil
+}
+
+// quoteDSNValue quotes value according to the libpq key/value connection string rules.
+func quoteDSNValue(s string) string {
+	if s != "" && !strings.ContainsAny(s, " '\\\t\n") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(s) + "'"
+}
@@ -4,76 +4,133 @@ import (
 	"context"
 )
 
+// Admin console commands returning the rows.
+const (
+	CommandStats     = "SHOW STATS"
+	CommandPools     = "SHOW POOLS"
+	CommandDatabases = "SHOW DATABASES"
+	CommandLists     = "SHOW LISTS"
+	CommandTotals    = "SHOW TOTALS"
+)
+
 // Stat represents stat row.
 type Stat struct {
-	Database                     string
-	TotalReceived                int64
-	TotalSent                    int64
-	TotalQueryTime               int64
-	TotalXactCount               int64
-	TotalXactTime                int64
-	TotalQueryCount              int64
-	TotalWaitTime                int64
-	TotalServerAssignmentCount   int64
-	TotalClientParseCount        int64
-	TotalServerParseCount        int64
-	TotalBindCount               int64
-	AverageReceived              int64
-	AverageSent                  int64
-	AverageQueryCount            int64
-	AverageQueryTime             int64
-	AverageXactTime              int64
-	AverageXactCount             int64
-	AverageWaitTime              int64
-	AverageServerAssignmentCount int64
-	AverageClientParseCount      int64
-	AverageServerParseCount      int64
-	AverageBindCount             int64
+	Database                     string `column:"database"`
+	TotalReceived                int64  `column:"total_received"`
+	TotalSent                    int64  `column:"total_sent"`
+	TotalQueryTime               int64  `column:"total_query_time"`
+	TotalXactCount               int64  `column:"total_xact_count"`
+	TotalXactTime                int64  `column:"total_xact_time"`
+	TotalQueryCount              int64  `column:"total_query_count"`
+	TotalWaitTime                int64  `column:"total_wait_time"`
+	TotalServerAssignmentCount   int64  `column:"total_server_assignment_count"`
+	TotalClientParseCount        int64  `column:"total_client_parse_count"`
+	TotalServerParseCount        int64  `column:"total_server_parse_count"`
+	TotalBindCount               int64  `column:"total_bind_count"`
+	AverageReceived              int64  `column:"avg_recv"`
+	AverageSent                  int64  `column:"avg_sent"`
+	AverageQueryCount            int64  `column:"avg_query_count"`
+	AverageQueryTime             int64  `column:"avg_query_time"`
+	AverageXactTime              int64  `column:"avg_xact_time"`
+	AverageXactCount             int64  `column:"avg_xact_count"`
+	AverageWaitTime              int64  `column:"avg_wait_time"`
+	AverageServerAssignmentCount int64  `column:"avg_server_assignment_count"`
+	AverageClientParseCount      int64  `column:"avg_client_parse_count"`
+	AverageServerParseCount      int64  `column:"avg_server_parse_count"`
+	AverageBindCount             int64  `column:"avg_bind_count"`
+	Extra                        Row
 }
 
 // Pool represents pool row.
 type Pool struct {
-	Database            string
-	User                string
-	Active              int64
-	Waiting             int64
-	CancelReq           int64
-	ActiveCancelReq     int64
-	WaitingCancelReq    int64
-	ServerActive        int64
-	ServerActiveCancel  int64
-	ServerBeingCanceled int64
-	ServerIdle          int64
-	ServerUsed          int64
-	ServerTested        int64
-	ServerLogin         int64
-	MaxWait             int64
-	MaxWaitUs           int64
-	PoolMode            string
+	Database            string `column:"database"`
+	User                string `column:"user"`
+	Active              int64  `column:"cl_active"`
+	Waiting             int64  `column:"cl_waiting"`
+	CancelReq           int64  `column:"cl_cancel_req"`
+	ActiveCancelReq     int64  `column:"cl_active_cancel_req"`
+	WaitingCancelReq    int64  `column:"cl_waiting_cancel_req"`
+	ServerActive        int64  `column:"sv_active"`
+	ServerActiveCancel  int64  `column:"sv_active_cancel"`
+	ServerBeingCanceled int64  `column:"sv_being_canceled"`
+	ServerIdle          int64  `column:"sv_idle"`
+	ServerUsed          int64  `column:"sv_used"`
+	ServerTested        int64  `column:"sv_tested"`
+	ServerLogin         int64  `column:"sv_login"`
+	MaxWait             int64  `column:"maxwait"`
+	MaxWaitUs           int64  `column:"maxwait_us"`
+	PoolMode            string `column:"pool_mode"`
+	LoadBalanceHosts    string `column:"load_balance_hosts"`
+	Extra               Row
 }
 
 // Database represents database row.
 type Database struct {
-	Name               string
-	Host               string
-	Port               int64
-	Database           string
-	ForceUser          string
-	PoolSize           int64
-	MinPoolSize        int64
-	ReservePoolSize    int64
-	PoolMode           string
-	MaxConnections     int64
-	CurrentConnections int64
-	Paused             int64
-	Disabled           int64
-	ServerLifetime     int64
+	Name                     string `column:"name"`
+	Host                     string `column:"host"`
+	Port                     int64  `column:"port"`
+	Database                 string `column:"database"`
+	ForceUser                string `column:"force_user"`
+	PoolSize                 int64  `column:"pool_size"`
+	MinPoolSize              int64  `column:"min_pool_size"`
+	ReservePoolSize          int64  `column:"reserve_pool_size,reserve_pool"` // renamed in PgBouncer 1.24 https://github.com/pgbouncer/pgbouncer/pull/1232
+	PoolMode                 string `column:"pool_mode"`
+	MaxConnections           int64  `column:"max_connections"`
+	CurrentConnections       int64  `column:"current_connections"`
+	MaxClientConnections     int64  `column:"max_client_connections"`
+	CurrentClientConnections int64  `column:"current_client_connections"`
+	Paused                   int64  `column:"paused"`
+	Disabled                 int64  `column:"disabled"`
+	ServerLifetime           int64  `column:"server_lifetime"`
+	LoadBalanceHosts         string `column:"load_balance_hosts"`
+	Extra                    Row
 }
 
 // List represents list row.
 type List struct {
-	List  string
-	Items int64
+	List  string `column:"list"`
+	Items int64  `column:"items"`
+	Extra Row
+}
+
+// Totals represents the stats summed across all databases, SHOW TOTALS returns them
+// as rows of the name and value columns, the column tags are the names of the rows.
+type Totals struct {
+	TotalServerAssignmentCount   int64 `column:"total_server_assignment_count"`
+	TotalXactCount               int64 `column:"total_xact_count"`
+	TotalQueryCount              int64 `column:"total_query_count"`
+	TotalReceived                int64 `column:"total_client_bytes"`
+	TotalSent                    int64 `column:"total_server_bytes"`
+	TotalXactTime                int64 `column:"total_xact_time"`
+	TotalQueryTime               int64 `column:"total_query_time"`
+	TotalWaitTime                int64 `column:"total_wait_time"`
+	TotalClientParseCount        int64 `column:"total_client_parse_count"`
+	TotalServerParseCount        int64 `column:"total_server_parse_count"`
+	TotalBindCount               int64 `column:"total_bind_count"`
+	AverageServerAssignmentCount int64 `column:"avg_server_assignment_count"`
+	AverageXactCount             int64 `column:"avg_xact_count"`
+	AverageQueryCount            int64 `column:"avg_query_count"`
+	AverageReceived              int64 `column:"avg_client_bytes"`
+	AverageSent                  int64 `column:"avg_server_bytes"`
+	AverageXactTime              int64 `column:"avg_xact_time"`
+	AverageQueryTime             int64 `column:"avg_query_time"`
+	AverageWaitTime              int64 `column:"avg_wait_time"`
+	AverageClientParseCount      int64 `column:"avg_client_parse_count"`
+	AverageServerParseCount      int64 `column:"avg_server_parse_count"`
+	AverageBindCount             int64 `column:"avg_bind_count"`
+	Extra                        Row
+}
+
+// Column represents a column returned by an admin console command.
+type Column struct {
+	Command string
+	Name    string
+}
+
+// UnknownColumn represents a column not known to the store, Count is the number of results it was returned in.
+type UnknownColumn struct {
+	Column
+	Count int64
 }
 
 // Store defines interface for accessing pgbouncer stats.
@@ -90,6 +147,12 @@ type Store interface {
 	// GetLists returns lists.
 	GetLists(ctx context.Context) ([]List, error)
 
+	// GetTotals returns totals, or nil when they are not available.
+	GetTotals(ctx context.Context) (*Totals, error)
+
+	// Query runs the admin console command and returns its rows.
+	Query(ctx context.Context, command string) ([]Row, error)
+
 	// Check checks the health of the store.
 	Check(ctx context.Context) error
 }
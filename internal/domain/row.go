@@ -0,0 +1,198 @@
+package domain
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Values holds numeric columns keyed by column name.
+type Values map[string]float64
+
+// Row holds the columns of a row which have no field in the typed representation,
+// numeric columns are stored in Values and the other columns in Labels.
+type Row struct {
+	Labels map[string]string
+	Values Values
+	// Columns holds the names of the columns returned by the store in order, including the columns
+	// which have a field in the typed representation. The fields of the other columns were not scanned.
+	Columns []string
+}
+
+// Record provides access to the columns of a row by name, both to the fields
+// of the typed representation and to the extra columns.
+type Record interface {
+	// Label returns the value of the column formatted as a label value, or an empty string
+	// when the row has no such column.
+	Label(column string) string
+
+	// Value returns the value of the numeric column, ok is false when the row has no such column.
+	Value(column string) (value float64, ok bool)
+}
+
+// Label implements Record.
+func (r Row) Label(column string) string { return recordLabel(struct{}{}, r, column) }
+
+// Value implements Record.
+func (r Row) Value(column string) (float64, bool) { return recordValue(struct{}{}, r, column) }
+
+// Label implements Record.
+func (s Stat) Label(column string) string { return recordLabel(s, s.Extra, column) }
+
+// Value implements Record.
+func (s Stat) Value(column string) (float64, bool) { return recordValue(s, s.Extra, column) }
+
+// Label implements Record.
+func (p Pool) Label(column string) string { return recordLabel(p, p.Extra, column) }
+
+// Value implements Record.
+func (p Pool) Value(column string) (float64, bool) { return recordValue(p, p.Extra, column) }
+
+// Label implements Record.
+func (d Database) Label(column string) string { return recordLabel(d, d.Extra, column) }
+
+// Value implements Record.
+func (d Database) Value(column string) (float64, bool) { return recordValue(d, d.Extra, column) }
+
+// Label implements Record.
+func (l List) Label(column string) string { return recordLabel(l, l.Extra, column) }
+
+// Value implements Record.
+func (l List) Value(column string) (float64, bool) { return recordValue(l, l.Extra, column) }
+
+// Label implements Record, the names SHOW TOTALS did not return have no value.
+func (t Totals) Label(column string) string {
+	if !slices.Contains(t.Extra.Columns, column) {
+		return ""
+	}
+	return recordLabel(t, t.Extra, column)
+}
+
+// Value implements Record, ok is false for the names SHOW TOTALS did not return
+// as older versions of pgbouncer do not return all of them.
+func (t Totals) Value(column string) (float64, bool) {
+	if !slices.Contains(t.Extra.Columns, column) {
+		return 0, false
+	}
+	return recordValue(t, t.Extra, column)
+}
+
+// Field returns a pointer to the field of the struct pointed to by row which holds the column,
+// the fields are declared using the column tag listing the names of the column.
+func Field(row any, column string) (any, bool) {
+	v := reflect.ValueOf(row).Elem()
+	idx, ok := columnFields(v.Type())[column]
+	if !ok {
+		return nil, false
+	}
+	return v.Field(idx).Addr().Interface(), true
+}
+
+// Extra returns a pointer to the extra columns of the struct pointed to by row.
+func Extra(row any) *Row {
+	v := reflect.ValueOf(row).Elem()
+	return v.FieldByName("Extra").Addr().Interface().(*Row)
+}
+
+// Columns returns the names of the columns of the row in order and their values keyed by the names,
+// row is a Row or a struct with the column tags. The columns are in the order returned by the store,
+// the extra columns of rows without the order follow sorted by name. Only the scanned fields of the
+// typed representation are included, the NULL extra columns are left out.
+func Columns(row any) ([]string, map[string]any) {
+	var (
+		names  []string
+		values = make(map[string]any)
+	)
+
+	v := reflect.ValueOf(struct{}{})
+	extra, ok := row.(Row)
+	if !ok {
+		v = reflect.ValueOf(row)
+		extra = v.FieldByName("Extra").Interface().(Row)
+	}
+
+	fields := columnFields(v.Type())
+	for _, name := range extra.Columns {
+		if idx, ok := fields[name]; ok {
+			names = append(names, name)
+			values[name] = v.Field(idx).Interface()
+		} else if label, ok := extra.Labels[name]; ok {
+			names = append(names, name)
+			values[name] = label
+		} else if value, ok := extra.Values[name]; ok {
+			names = append(names, name)
+			values[name] = value
+		}
+	}
+
+	var unordered []string
+	for name, label := range extra.Labels {
+		if _, ok := values[name]; !ok {
+			unordered = append(unordered, name)
+			values[name] = label
+		}
+	}
+	for name, value := range extra.Values {
+		if _, ok := values[name]; !ok {
+			unordered = append(unordered, name)
+			values[name] = value
+		}
+	}
+	slices.Sort(unordered)
+	return append(names, unordered...), values
+}
+
+func recordLabel(row any, extra Row, column string) string {
+	v := reflect.ValueOf(row)
+	if idx, ok := columnFields(v.Type())[column]; ok {
+		field := v.Field(idx)
+		if field.Kind() == reflect.String {
+			return field.String()
+		}
+		return strconv.FormatInt(field.Int(), 10)
+	}
+	if label, ok := extra.Labels[column]; ok {
+		return label
+	}
+	if value, ok := extra.Values[column]; ok {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return ""
+}
+
+func recordValue(row any, extra Row, column string) (float64, bool) {
+	v := reflect.ValueOf(row)
+	if idx, ok := columnFields(v.Type())[column]; ok {
+		field := v.Field(idx)
+		if field.Kind() != reflect.Int64 {
+			return 0, false
+		}
+		return float64(field.Int()), true
+	}
+	value, ok := extra.Values[column]
+	return value, ok
+}
+
+var fieldsCache sync.Map // reflect.Type -> map[string]int
+
+// columnFields returns the field indexes of the struct type by column name.
+func columnFields(t reflect.Type) map[string]int {
+	if fields, ok := fieldsCache.Load(t); ok {
+		return fields.(map[string]int)
+	}
+
+	fields := make(map[string]int)
+	for i := range t.NumField() {
+		tag, ok := t.Field(i).Tag.Lookup("column")
+		if !ok {
+			continue
+		}
+		for name := range strings.SplitSeq(tag, ",") {
+			fields[name] = i
+		}
+	}
+	fieldsCache.Store(t, fields)
+	return fields
+}
@@ -0,0 +1,108 @@
+package logstore
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// Follower is an io.Reader which follows a growing file like tail -F.
+// It waits for new data at the end of the file, starts over when the file is truncated
+// and reopens it when it is rotated.
+type Follower struct {
+	ctx      context.Context
+	path     string
+	interval time.Duration
+	file     *os.File
+}
+
+// Follow opens the file at path and returns a Follower reading the data appended to it from now on,
+// the existing lines are skipped like tail -F does. Interval is the time between checks for new data.
+// Reading stops when ctx is done.
+func Follow(ctx context.Context, path string, interval time.Duration) (*Follower, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &Follower{
+		ctx:      ctx,
+		path:     path,
+		interval: interval,
+		file:     file,
+	}, nil
+}
+
+// Read implements io.Reader.Read.
+func (f *Follower) Read(p []byte) (int, error) {
+	for {
+		n, err := f.file.Read(p)
+		if n > 0 || (err != nil && err != io.EOF) {
+			return n, err
+		}
+
+		select {
+		case <-f.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(f.interval):
+		}
+
+		if err := f.checkRotated(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// checkRotated reopens the file when it was replaced and rewinds it when it was truncated.
+func (f *Follower) checkRotated() error {
+	current, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	latest, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		// the file was moved away and the new one was not created yet
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !os.SameFile(current, latest) {
+		// the lines written to the old file before it was rotated are read first
+		offset, err := f.file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		if current.Size() > offset {
+			return nil
+		}
+
+		file, err := os.Open(f.path)
+		if err != nil {
+			return err
+		}
+		_ = f.file.Close()
+		f.file = file
+		return nil
+	}
+
+	offset, err := f.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if latest.Size() < offset {
+		_, err = f.file.Seek(0, io.SeekStart)
+	}
+	return err
+}
+
+// Close closes the followed file.
+func (f *Follower) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,91 @@
+package logstore
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func appendLine(t *testing.T, path string, line string) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = file.WriteString(line + "\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
+
+func TestFollower(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pgbouncer.log")
+	appendLine(t, path, "history")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	follower, err := Follow(ctx, path, time.Millisecond)
+	require.NoError(t, err)
+	defer follower.Close() //nolint:errcheck
+
+	scanner := bufio.NewScanner(follower)
+	next := func() string {
+		require.True(t, scanner.Scan())
+		return scanner.Text()
+	}
+
+	// the lines logged before following are skipped
+	appendLine(t, path, "appended")
+	require.Equal(t, "appended", next())
+
+	// truncated file is read from the beginning
+	require.NoError(t, os.WriteFile(path, []byte("a\n"), 0o600))
+	require.Equal(t, "a", next())
+
+	// rotated file is reopened
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendLine(t, path, "rotated")
+	require.Equal(t, "rotated", next())
+
+	cancel()
+	require.False(t, scanner.Scan())
+	require.NoError(t, scanner.Err())
+}
+
+func TestFollowerRotationBetweenWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pgbouncer.log")
+	appendLine(t, path, "history")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	follower, err := Follow(ctx, path, time.Millisecond*50)
+	require.NoError(t, err)
+	defer follower.Close() //nolint:errcheck
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(follower)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	appendLine(t, path, "first")
+	require.Equal(t, "first", <-lines)
+
+	// the follower waits for new data while the file is written, rotated and written again
+	appendLine(t, path, "before rotation")
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendLine(t, path, "after rotation")
+
+	require.Equal(t, "before rotation", <-lines)
+	require.Equal(t, "after rotation", <-lines)
+
+	cancel()
+	for range lines {
+	}
+}
@@ -0,0 +1,97 @@
+package logstore
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// statsPrefixRe matches the prefix of the stats lines, stats: follows the LOG level or
+// the process of a syslog line. Older versions of pgbouncer log Stats: capitalized.
+var statsPrefixRe = regexp.MustCompile(`(?i)(?:\bLOG |\]: )stats: `)
+
+// averages represents the averages of a single stats log line.
+type averages struct {
+	xacts         int64
+	queries       int64
+	clientParses  int64
+	serverParses  int64
+	binds         int64
+	received      int64
+	sent          int64
+	xactTime      int64
+	queryTime     int64
+	waitTime      int64
+	hasXactCount  bool
+	hasQueryCount bool
+}
+
+// parseLine parses the periodic stats line logged by pgbouncer, for example:
+//
+//	2024-01-02 10:00:00.000 UTC [1] LOG stats: 10 xacts/s, 20 queries/s, in 300 B/s, out 400 B/s, xact 500 us, query 250 us, wait 5 us
+//
+// The returned bool is false for lines which do not contain stats. Unknown items are skipped
+// so that items added by newer pgbouncer versions do not break parsing.
+func parseLine(line string) (averages, bool, error) {
+	loc := statsPrefixRe.FindStringIndex(line)
+	if loc == nil {
+		return averages{}, false, nil
+	}
+
+	var avg averages
+	for _, item := range strings.Split(line[loc[1]:], ",") {
+		key, value, err := parseItem(strings.TrimSpace(item))
+		if err != nil {
+			return averages{}, false, fmt.Errorf("invalid stats item %q: %v", item, err)
+		}
+
+		switch key {
+		case "xacts/s":
+			avg.xacts = value
+			avg.hasXactCount = true
+		case "queries/s", "req/s":
+			avg.queries = value
+			avg.hasQueryCount = true
+		case "client parses/s":
+			avg.clientParses = value
+		case "server parses/s":
+			avg.serverParses = value
+		case "binds/s":
+			avg.binds = value
+		case "in":
+			avg.received = value
+		case "out":
+			avg.sent = value
+		case "xact":
+			avg.xactTime = value
+		case "query":
+			avg.queryTime = value
+		case "wait":
+			avg.waitTime = value
+		}
+	}
+
+	if !avg.hasXactCount && !avg.hasQueryCount {
+		return averages{}, false, nil
+	}
+	return avg, true, nil
+}
+
+// parseItem parses items in the "10 xacts/s" and "in 300 B/s" formats.
+func parseItem(item string) (string, int64, error) {
+	fields := strings.Fields(item)
+	if len(fields) < 2 {
+		return "", 0, fmt.Errorf("expected value and name")
+	}
+
+	if value, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+		return strings.Join(fields[1:], " "), value, nil
+	}
+
+	value, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	return fields[0], value, nil
+}
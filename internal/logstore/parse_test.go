@@ -0,0 +1,89 @@
+package logstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	parseLineCases = []struct {
+		name     string
+		line     string
+		expected averages
+		ok       bool
+		err      string
+	}{
+		{
+			name: "current format",
+			line: "2024-03-04 10:01:00.114 UTC [7] LOG stats: 10 xacts/s, 20 queries/s, 2 client parses/s, 1 server parses/s, 3 binds/s, in 300 B/s, out 400 B/s, xact 500 us, query 250 us, wait 5 us",
+			expected: averages{
+				xacts:         10,
+				queries:       20,
+				clientParses:  2,
+				serverParses:  1,
+				binds:         3,
+				received:      300,
+				sent:          400,
+				xactTime:      500,
+				queryTime:     250,
+				waitTime:      5,
+				hasXactCount:  true,
+				hasQueryCount: true,
+			},
+			ok: true,
+		},
+		{
+			name: "old format",
+			line: "2017-01-10 08:01:00.000 UTC [1] LOG Stats: 4 req/s, in 50 B/s, out 60 B/s, query 300 us",
+			expected: averages{
+				queries:       4,
+				received:      50,
+				sent:          60,
+				queryTime:     300,
+				hasQueryCount: true,
+			},
+			ok: true,
+		},
+		{
+			name: "syslog prefix and unknown item",
+			line: "Mar  4 10:01:00 db1 pgbouncer[7]: stats: 1 xacts/s, 7 frobs/s, in 3 B/s",
+			expected: averages{
+				xacts:        1,
+				received:     3,
+				hasXactCount: true,
+			},
+			ok: true,
+		},
+		{
+			name: "other line",
+			line: "2024-03-04 10:00:00.113 UTC [7] LOG listening on 0.0.0.0:6432",
+			ok:   false,
+		},
+		{
+			name: "stats in other message",
+			line: "2024-03-04 10:02:00.000 UTC [7] LOG C-0x55d7d2b1c2a0: app/app@10.0.0.5:51234 closing because: query failed: relation stats: 1 does not exist",
+			ok:   false,
+		},
+		{
+			name: "invalid value",
+			line: "2024-03-04 10:01:00.114 UTC [7] LOG stats: x xacts/s, 20 queries/s",
+			err:  `invalid stats item "x xacts/s": strconv.ParseInt: parsing "xacts/s": invalid syntax`,
+		},
+	}
+)
+
+func TestParseLine(t *testing.T) {
+	for _, cs := range parseLineCases {
+		t.Run(cs.name, func(t *testing.T) {
+			avg, ok, err := parseLine(cs.line)
+			if cs.err != "" {
+				require.EqualError(t, err, cs.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, cs.ok, ok)
+			require.Equal(t, cs.expected, avg)
+		})
+	}
+}
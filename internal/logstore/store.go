@@ -0,0 +1,231 @@
+package logstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+)
+
+// Database is the value of the database label of the stats, pgbouncer logs the stats summed across all databases.
+const Database = "all"
+
+var errClosed = errors.New("log stream closed")
+
+// Store is a domain.Store which parses the periodic stats lines of the pgbouncer log.
+// Totals are computed by multiplying the logged averages by the stats period. Pools, databases
+// and lists are not logged by pgbouncer, so they are always empty.
+type Store struct {
+	period int64
+
+	mut  sync.RWMutex
+	stat *domain.Stat
+	err  error
+}
+
+// New returns a new Store, period is the stats_period of pgbouncer.
+func New(period time.Duration) *Store {
+	return &Store{
+		period: int64(period / time.Second),
+	}
+}
+
+// Consume reads the log lines from r until it is exhausted or ctx is done.
+// The store reports unhealthy once the reading stops.
+func (s *Store) Consume(ctx context.Context, r io.Reader) {
+	// lines are read without a length limit, a long query logged in an error message must not stop the reading
+	reader := bufio.NewReader(r)
+	var err error
+	for ctx.Err() == nil {
+		var line string
+		line, err = reader.ReadString('\n')
+		if line != "" {
+			if err := s.addLine(strings.TrimRight(line, "\r\n")); err != nil {
+				log.Printf("could not parse log line: %v", err)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = fmt.Errorf("could not read log: %v", err)
+	} else {
+		err = errClosed
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.err = err
+}
+
+func (s *Store) addLine(line string) error {
+	avg, ok, err := parseLine(line)
+	if err != nil || !ok {
+		return err
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.stat == nil {
+		s.stat = &domain.Stat{Database: Database, Extra: domain.Row{Columns: statsColumns}}
+	}
+	addAverages(s.stat, avg, s.period)
+	return nil
+}
+
+// GetStats returns stats.
+func (s *Store) GetStats(ctx context.Context) ([]domain.Stat, error) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	if s.stat == nil {
+		return nil, nil
+	}
+	return []domain.Stat{*s.stat}, nil
+}
+
+// GetPools returns pools.
+func (s *Store) GetPools(ctx context.Context) ([]domain.Pool, error) {
+	return nil, nil
+}
+
+// GetDatabases returns databases.
+func (s *Store) GetDatabases(ctx context.Context) ([]domain.Database, error) {
+	return nil, nil
+}
+
+// GetLists returns lists.
+func (s *Store) GetLists(ctx context.Context) ([]domain.List, error) {
+	return nil, nil
+}
+
+// GetTotals returns totals, they are the same as the stats as pgbouncer logs the stats summed across all databases.
+func (s *Store) GetTotals(ctx context.Context) (*domain.Totals, error) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	if s.stat == nil {
+		return nil, nil
+	}
+	return &domain.Totals{
+		TotalXactCount:          s.stat.TotalXactCount,
+		TotalQueryCount:         s.stat.TotalQueryCount,
+		TotalReceived:           s.stat.TotalReceived,
+		TotalSent:               s.stat.TotalSent,
+		TotalXactTime:           s.stat.TotalXactTime,
+		TotalQueryTime:          s.stat.TotalQueryTime,
+		TotalWaitTime:           s.stat.TotalWaitTime,
+		TotalClientParseCount:   s.stat.TotalClientParseCount,
+		TotalServerParseCount:   s.stat.TotalServerParseCount,
+		TotalBindCount:          s.stat.TotalBindCount,
+		AverageXactCount:        s.stat.AverageXactCount,
+		AverageQueryCount:       s.stat.AverageQueryCount,
+		AverageReceived:         s.stat.AverageReceived,
+		AverageSent:             s.stat.AverageSent,
+		AverageXactTime:         s.stat.AverageXactTime,
+		AverageQueryTime:        s.stat.AverageQueryTime,
+		AverageWaitTime:         s.stat.AverageWaitTime,
+		AverageClientParseCount: s.stat.AverageClientParseCount,
+		AverageServerParseCount: s.stat.AverageServerParseCount,
+		AverageBindCount:        s.stat.AverageBindCount,
+		Extra:                   domain.Row{Columns: totalsColumns},
+	}, nil
+}
+
+// statsColumns are the names of the columns of the stats computed from the log, the server
+// assignments and the total wait time are not logged.
+var statsColumns = []string{
+	"database",
+	"total_xact_count",
+	"total_query_count",
+	"total_received",
+	"total_sent",
+	"total_xact_time",
+	"total_query_time",
+	"total_client_parse_count",
+	"total_server_parse_count",
+	"total_bind_count",
+	"avg_xact_count",
+	"avg_query_count",
+	"avg_recv",
+	"avg_sent",
+	"avg_xact_time",
+	"avg_query_time",
+	"avg_wait_time",
+	"avg_client_parse_count",
+	"avg_server_parse_count",
+	"avg_bind_count",
+}
+
+// totalsColumns are the names of the totals computed from the log.
+var totalsColumns = []string{
+	"total_xact_count",
+	"total_query_count",
+	"total_client_bytes",
+	"total_server_bytes",
+	"total_xact_time",
+	"total_query_time",
+	"total_client_parse_count",
+	"total_server_parse_count",
+	"total_bind_count",
+	"avg_xact_count",
+	"avg_query_count",
+	"avg_client_bytes",
+	"avg_server_bytes",
+	"avg_xact_time",
+	"avg_query_time",
+	"avg_wait_time",
+	"avg_client_parse_count",
+	"avg_server_parse_count",
+	"avg_bind_count",
+}
+
+// Query returns an error, the log contains no results of admin console commands.
+func (s *Store) Query(ctx context.Context, command string) ([]domain.Row, error) {
+	return nil, errors.New("queries are not supported by the log store")
+}
+
+// Check checks the health of the store.
+func (s *Store) Check(ctx context.Context) error {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	return s.err
+}
+
+// addAverages sets the averages of stat and adds the counts accumulated during period seconds to its totals.
+func addAverages(stat *domain.Stat, avg averages, period int64) {
+	xacts := avg.xacts * period
+	queries := avg.queries * period
+
+	stat.TotalXactCount += xacts
+	stat.TotalQueryCount += queries
+	stat.TotalXactTime += avg.xactTime * xacts
+	stat.TotalQueryTime += avg.queryTime * queries
+	stat.TotalReceived += avg.received * period
+	stat.TotalSent += avg.sent * period
+	stat.TotalClientParseCount += avg.clientParses * period
+	stat.TotalServerParseCount += avg.serverParses * period
+	stat.TotalBindCount += avg.binds * period
+
+	stat.AverageXactCount = avg.xacts
+	stat.AverageQueryCount = avg.queries
+	stat.AverageXactTime = avg.xactTime
+	stat.AverageQueryTime = avg.queryTime
+	stat.AverageReceived = avg.received
+	stat.AverageSent = avg.sent
+	stat.AverageWaitTime = avg.waitTime
+	stat.AverageClientParseCount = avg.clientParses
+	stat.AverageServerParseCount = avg.serverParses
+	stat.AverageBindCount = avg.binds
+}
@@ -0,0 +1,143 @@
+package logstore
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	consumeCases = []struct {
+		name     string
+		fixture  string
+		expected []domain.Stat
+	}{
+		{
+			name:    "pgbouncer 1.21",
+			fixture: "testdata/pgbouncer-1.21.log",
+			expected: []domain.Stat{
+				{
+					Database:              Database,
+					TotalXactCount:        900,
+					TotalQueryCount:       1680,
+					TotalXactTime:         600_000,
+					TotalQueryTime:        492_000,
+					TotalReceived:         24_000,
+					TotalSent:             36_000,
+					TotalClientParseCount: 120,
+					TotalServerParseCount: 60,
+					TotalBindCount:        180,
+					AverageXactCount:      5,
+					AverageQueryCount:     8,
+					AverageXactTime:       1000,
+					AverageQueryTime:      400,
+					AverageReceived:       100,
+					AverageSent:           200,
+					Extra:                 domain.Row{Columns: statsColumns},
+				},
+			},
+		},
+		{
+			name:    "pgbouncer 1.12",
+			fixture: "testdata/pgbouncer-1.12.log",
+			expected: []domain.Stat{
+				{
+					Database:          Database,
+					TotalXactCount:    120,
+					TotalQueryCount:   240,
+					TotalXactTime:     84_000,
+					TotalQueryTime:    72_000,
+					TotalReceived:     3000,
+					TotalSent:         3600,
+					AverageXactCount:  2,
+					AverageQueryCount: 4,
+					AverageXactTime:   700,
+					AverageQueryTime:  300,
+					AverageReceived:   50,
+					AverageSent:       60,
+					AverageWaitTime:   10,
+					Extra:             domain.Row{Columns: statsColumns},
+				},
+			},
+		},
+	}
+)
+
+func TestConsume(t *testing.T) {
+	for _, cs := range consumeCases {
+		t.Run(cs.name, func(t *testing.T) {
+			file, err := os.Open(cs.fixture)
+			require.NoError(t, err)
+			defer file.Close() //nolint:errcheck
+
+			store := New(time.Minute)
+			store.Consume(context.Background(), file)
+
+			stats, err := store.GetStats(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, cs.expected, stats)
+			require.EqualError(t, store.Check(context.Background()), "log stream closed")
+		})
+	}
+}
+
+func TestGetTotals(t *testing.T) {
+	file, err := os.Open("testdata/pgbouncer-1.12.log")
+	require.NoError(t, err)
+	defer file.Close() //nolint:errcheck
+
+	store := New(time.Minute)
+	store.Consume(context.Background(), file)
+
+	totals, err := store.GetTotals(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &domain.Totals{
+		TotalXactCount:    120,
+		TotalQueryCount:   240,
+		TotalXactTime:     84_000,
+		TotalQueryTime:    72_000,
+		TotalReceived:     3000,
+		TotalSent:         3600,
+		AverageXactCount:  2,
+		AverageQueryCount: 4,
+		AverageXactTime:   700,
+		AverageQueryTime:  300,
+		AverageReceived:   50,
+		AverageSent:       60,
+		AverageWaitTime:   10,
+		Extra:             domain.Row{Columns: totalsColumns},
+	}, totals)
+}
+
+func TestGetStatsBeforeFirstLine(t *testing.T) {
+	store := New(time.Minute)
+	store.Consume(context.Background(), strings.NewReader("LOG listening on 0.0.0.0:6432\n"))
+
+	stats, err := store.GetStats(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, stats)
+
+	totals, err := store.GetTotals(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, totals)
+}
+
+func TestConsumeLongLine(t *testing.T) {
+	line := "2024-03-04 10:01:00.114 UTC [7] LOG stats: 10 xacts/s, 20 queries/s"
+	long := "2024-03-04 10:00:30.000 UTC [7] WARNING query: " + strings.Repeat("x", 1<<20)
+
+	store := New(time.Minute)
+	store.Consume(context.Background(), strings.NewReader(long+"\n"+line))
+
+	stats, err := store.GetStats(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, int64(600), stats[0].TotalXactCount)
+	require.EqualError(t, store.Check(context.Background()), "log stream closed")
+}
@@ -0,0 +1,221 @@
+// Package mapping defines the metrics exported from the columns returned by the admin console.
+package mapping
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Types of the metrics.
+const (
+	TypeGauge   = "gauge"
+	TypeCounter = "counter"
+)
+
+// Commands are the admin console commands whose columns can be mapped.
+var Commands = []string{
+	domain.CommandStats,
+	domain.CommandPools,
+	domain.CommandDatabases,
+	domain.CommandLists,
+	domain.CommandTotals,
+}
+
+var (
+	metricNameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRe  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Mapping maps a numeric column returned by an admin console command to a metric, the value
+// is multiplied by Scale and labeled by the values of the label columns of the row.
+// Mappings sharing the metric name use the first column present in the row.
+type Mapping struct {
+	Command string
+	Column  string
+	Metric  string
+	Help    string
+	Type    string
+	Scale   float64
+	Labels  []string
+}
+
+type file struct {
+	Mappings []fileMapping `yaml:"mappings"`
+}
+
+type fileMapping struct {
+	Command string   `yaml:"command"`
+	Column  string   `yaml:"column"`
+	Metric  string   `yaml:"metric"`
+	Help    string   `yaml:"help"`
+	Type    string   `yaml:"type"`
+	Scale   *float64 `yaml:"scale"`
+	Labels  []string `yaml:"labels"`
+}
+
+//go:embed default.yml
+var defaultData []byte
+
+var defaults = mustParse(defaultData)
+
+// Default returns the built-in mappings.
+func Default() []Mapping {
+	return slices.Clone(defaults)
+}
+
+// Load reads additional mappings from the YAML file at path, they are validated
+// together with the built-in mappings.
+func Load(path string) ([]Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read mappings file: %v", err)
+	}
+
+	mappings, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mappings file %v: %v", path, err)
+	}
+	return mappings, nil
+}
+
+// Parse parses additional mappings from YAML data, they are validated together with the built-in mappings.
+func Parse(data []byte) ([]Mapping, error) {
+	return parse(data, defaults)
+}
+
+// Columns returns the columns used by the mappings, both the mapped and the label columns.
+func Columns(mappings []Mapping) []domain.Column {
+	var columns []domain.Column
+	add := func(col domain.Column) {
+		if !slices.Contains(columns, col) {
+			columns = append(columns, col)
+		}
+	}
+	for _, m := range mappings {
+		add(domain.Column{Command: m.Command, Name: m.Column})
+		for _, label := range m.Labels {
+			add(domain.Column{Command: m.Command, Name: label})
+		}
+	}
+	return columns
+}
+
+// CheckConflicts checks that mappings do not define metrics which are already defined by the existing
+// mappings differently, metrics defined by several mappings have to agree on everything except the column and scale.
+func CheckConflicts(existing []Mapping, mappings []Mapping) error {
+	existing = slices.Clip(existing)
+	for _, m := range mappings {
+		if err := checkConflict(existing, m); err != nil {
+			return err
+		}
+		existing = append(existing, m)
+	}
+	return nil
+}
+
+func mustParse(data []byte) []Mapping {
+	mappings, err := parse(data, nil)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default mappings: %v", err))
+	}
+	return mappings
+}
+
+func parse(data []byte, existing []Mapping) ([]Mapping, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var f file
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	existing = slices.Clip(existing)
+	mappings := make([]Mapping, 0, len(f.Mappings))
+
+	for i, fm := range f.Mappings {
+		m := fm.mapping()
+		if !slices.Contains(Commands, m.Command) {
+			return nil, fmt.Errorf("mappings[%v].command: unknown command %q, must be one of %v", i, fm.Command, strings.Join(Commands, ", "))
+		}
+		if err := m.validate(); err != nil {
+			return nil, fmt.Errorf("mappings[%v].%v", i, err)
+		}
+		if err := checkConflict(existing, m); err != nil {
+			return nil, fmt.Errorf("mappings[%v].%v", i, err)
+		}
+		existing = append(existing, m)
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+func (fm fileMapping) mapping() Mapping {
+	m := Mapping{
+		Command: strings.ToUpper(strings.TrimSpace(fm.Command)),
+		Column:  fm.Column,
+		Metric:  fm.Metric,
+		Help:    fm.Help,
+		Type:    fm.Type,
+		Scale:   1,
+		Labels:  fm.Labels,
+	}
+	if m.Type == "" {
+		m.Type = TypeGauge
+	}
+	if m.Help == "" {
+		m.Help = fmt.Sprintf("Value of the %v column returned by %v.", m.Column, m.Command)
+	}
+	if fm.Scale != nil {
+		m.Scale = *fm.Scale
+	}
+	return m
+}
+
+// checkConflict checks that m agrees with the first existing mapping of the same metric.
+func checkConflict(existing []Mapping, m Mapping) error {
+	idx := slices.IndexFunc(existing, func(prev Mapping) bool { return prev.Metric == m.Metric })
+	if idx < 0 {
+		return nil
+	}
+	prev := existing[idx]
+	if prev.Command != m.Command || prev.Type != m.Type || prev.Help != m.Help || !slices.Equal(prev.Labels, m.Labels) {
+		return fmt.Errorf("metric: %v is already defined with a different command, type, help or labels", m.Metric)
+	}
+	return nil
+}
+
+func (m Mapping) validate() error {
+	if m.Column == "" {
+		return errors.New("column: must be set")
+	}
+	if !metricNameRe.MatchString(m.Metric) {
+		return fmt.Errorf("metric: invalid metric name %q, must match %v", m.Metric, metricNameRe)
+	}
+	if m.Type != TypeGauge && m.Type != TypeCounter {
+		return fmt.Errorf("type: unknown type %q, must be one of %v, %v", m.Type, TypeGauge, TypeCounter)
+	}
+	if m.Scale == 0 {
+		return errors.New("scale: must not be zero")
+	}
+	for i, label := range m.Labels {
+		if !labelNameRe.MatchString(label) || strings.HasPrefix(label, "__") {
+			return fmt.Errorf("labels[%v]: invalid label name %q", i, label)
+		}
+		if slices.Contains(m.Labels[:i], label) {
+			return fmt.Errorf("labels[%v]: duplicate label %q", i, label)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefault(t *testing.T) {
+	mappings := Default()
+	require.NotEmpty(t, mappings)
+
+	// the totals only have values for the names returned by SHOW TOTALS
+	var totals []string
+	typ := reflect.TypeFor[domain.Totals]()
+	for i := range typ.NumField() {
+		if tag, ok := typ.Field(i).Tag.Lookup("column"); ok {
+			totals = append(totals, tag)
+		}
+	}
+
+	records := map[string]domain.Record{
+		domain.CommandStats:     domain.Stat{},
+		domain.CommandPools:     domain.Pool{},
+		domain.CommandDatabases: domain.Database{},
+		domain.CommandLists:     domain.List{},
+		domain.CommandTotals:    domain.Totals{Extra: domain.Row{Columns: totals}},
+	}
+
+	// the built-in mappings use the fields of the typed rows, so they are always known to the stores
+	for _, m := range mappings {
+		_, ok := records[m.Command].Value(m.Column)
+		require.True(t, ok, "%v %v", m.Command, m.Column)
+		if m.Command == domain.CommandTotals {
+			continue
+		}
+		// the metrics of the other commands predate the mappings and keep their types and units
+		require.Equal(t, TypeGauge, m.Type)
+		require.Equal(t, float64(1), m.Scale)
+	}
+}
+
+func TestParse(t *testing.T) {
+	data := `
+mappings:
+  - command: show pools
+    column: sv_new
+    metric: pgbouncer_exporter_pools_new_server
+    labels: [database, user]
+  - command: SHOW POOLS
+    column: maxwait_us
+    metric: pgbouncer_exporter_pools_max_wait_seconds
+    help: Longest waiting time in seconds.
+    type: counter
+    scale: 0.000001
+    labels: [database, user, pool_mode]
+  - command: SHOW STATS
+    column: total_new_time
+    metric: pgbouncer_exporter_stats_total_query_time
+    help: Total number of microseconds spent by pgbouncer when actively connected to PostgreSQL.
+    labels: [database]
+`
+
+	mappings, err := Parse([]byte(data))
+	require.NoError(t, err)
+	require.Equal(t, []Mapping{
+		{
+			Command: domain.CommandPools,
+			Column:  "sv_new",
+			Metric:  "pgbouncer_exporter_pools_new_server",
+			Help:    "Value of the sv_new column returned by SHOW POOLS.",
+			Type:    TypeGauge,
+			Scale:   1,
+			Labels:  []string{"database", "user"},
+		},
+		{
+			Command: domain.CommandPools,
+			Column:  "maxwait_us",
+			Metric:  "pgbouncer_exporter_pools_max_wait_seconds",
+			Help:    "Longest waiting time in seconds.",
+			Type:    TypeCounter,
+			Scale:   0.000001,
+			Labels:  []string{"database", "user", "pool_mode"},
+		},
+		{
+			Command: domain.CommandStats,
+			Column:  "total_new_time",
+			Metric:  "pgbouncer_exporter_stats_total_query_time",
+			Help:    "Total number of microseconds spent by pgbouncer when actively connected to PostgreSQL.",
+			Type:    TypeGauge,
+			Scale:   1,
+			Labels:  []string{"database"},
+		},
+	}, mappings)
+
+	require.Equal(t, []domain.Column{
+		{Command: domain.CommandPools, Name: "sv_new"},
+		{Command: domain.CommandPools, Name: "database"},
+		{Command: domain.CommandPools, Name: "user"},
+		{Command: domain.CommandPools, Name: "maxwait_us"},
+		{Command: domain.CommandPools, Name: "pool_mode"},
+		{Command: domain.CommandStats, Name: "total_new_time"},
+		{Command: domain.CommandStats, Name: "database"},
+	}, Columns(mappings))
+}
+
+var (
+	parseErrorCases = []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "unknown key",
+			data: "mappings:\n  - command: SHOW STATS\n    colum: total_new\n",
+			err:  "line 3: field colum not found",
+		},
+		{
+			name: "unknown command",
+			data: "mappings:\n  - command: SHOW CLIENTS\n    column: port\n    metric: clients_port\n",
+			err:  `mappings[0].command: unknown command "SHOW CLIENTS", must be one of SHOW STATS, SHOW POOLS, SHOW DATABASES, SHOW LISTS, SHOW TOTALS`,
+		},
+		{
+			name: "missing column",
+			data: "mappings:\n  - command: SHOW STATS\n    metric: stats_new\n",
+			err:  "mappings[0].column: must be set",
+		},
+		{
+			name: "invalid metric",
+			data: "mappings:\n  - command: SHOW STATS\n    column: total_new\n    metric: stats-new\n",
+			err:  `mappings[0].metric: invalid metric name "stats-new"`,
+		},
+		{
+			name: "unknown type",
+			data: "mappings:\n  - command: SHOW STATS\n    column: total_new\n    metric: stats_new\n    type: histogram\n",
+			err:  `mappings[0].type: unknown type "histogram", must be one of gauge, counter`,
+		},
+		{
+			name: "zero scale",
+			data: "mappings:\n  - command: SHOW STATS\n    column: total_new\n    metric: stats_new\n    scale: 0\n",
+			err:  "mappings[0].scale: must not be zero",
+		},
+		{
+			name: "invalid label",
+			data: "mappings:\n  - command: SHOW STATS\n    column: total_new\n    metric: stats_new\n    labels: [database, __name]\n",
+			err:  `mappings[0].labels[1]: invalid label name "__name"`,
+		},
+		{
+			name: "duplicate label",
+			data: "mappings:\n  - command: SHOW STATS\n    column: total_new\n    metric: stats_new\n    labels: [database, database]\n",
+			err:  `mappings[0].labels[1]: duplicate label "database"`,
+		},
+		{
+			name: "conflicting metric",
+			data: "mappings:\n  - command: SHOW STATS\n    column: total_new\n    metric: pgbouncer_exporter_stats_total_sent\n    labels: [database]\n",
+			err:  "mappings[0].metric: pgbouncer_exporter_stats_total_sent is already defined with a different command, type, help or labels",
+		},
+	}
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, cs := range parseErrorCases {
+		t.Run(cs.name, func(t *testing.T) {
+			_, err := Parse([]byte(cs.data))
+			require.ErrorContains(t, err, cs.err)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mappings.yml")
+	require.NoError(t, os.WriteFile(path, []byte("mappings: []\n"), 0o600))
+
+	mappings, err := Load(path)
+	require.NoError(t, err)
+	require.Empty(t, mappings)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yml"))
+	require.ErrorContains(t, err, "could not read mappings file")
+}
@@ -0,0 +1,100 @@
+package mapping
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Query is a custom admin console command along with the mappings of its columns to metrics.
+type Query struct {
+	Command  string
+	Mappings []Mapping
+}
+
+type queriesFile struct {
+	Queries []fileQuery `yaml:"queries"`
+}
+
+type fileQuery struct {
+	Command string        `yaml:"command"`
+	Metrics []fileMapping `yaml:"metrics"`
+}
+
+// LoadQueries reads custom queries from the YAML file at path.
+func LoadQueries(path string) ([]Query, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read queries file: %v", err)
+	}
+
+	queries, err := ParseQueries(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid queries file %v: %v", path, err)
+	}
+	return queries, nil
+}
+
+// ParseQueries parses custom queries from YAML data, their mappings are validated
+// together with the built-in mappings.
+func ParseQueries(data []byte) ([]Query, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var f queriesFile
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	queries := make([]Query, 0, len(f.Queries))
+	existing := slices.Clip(defaults)
+
+	for i, fq := range f.Queries {
+		q := Query{Command: strings.ToUpper(strings.TrimSpace(fq.Command))}
+
+		// only SHOW commands are allowed as the others change the state of pgbouncer
+		if !strings.HasPrefix(q.Command, "SHOW ") {
+			return nil, fmt.Errorf("queries[%v].command: must be a SHOW command, got %q", i, fq.Command)
+		}
+		if slices.Contains(Commands, q.Command) {
+			return nil, fmt.Errorf("queries[%v].command: %v is queried by the exporter, use the mappings file instead", i, q.Command)
+		}
+		if slices.ContainsFunc(queries, func(prev Query) bool { return prev.Command == q.Command }) {
+			return nil, fmt.Errorf("queries[%v].command: duplicate command %v", i, q.Command)
+		}
+		if len(fq.Metrics) == 0 {
+			return nil, fmt.Errorf("queries[%v].metrics: at least one metric must be defined", i)
+		}
+
+		for j, fm := range fq.Metrics {
+			fm.Command = q.Command
+			m := fm.mapping()
+			if err := m.validate(); err != nil {
+				return nil, fmt.Errorf("queries[%v].metrics[%v].%v", i, j, err)
+			}
+			if err := checkConflict(existing, m); err != nil {
+				return nil, fmt.Errorf("queries[%v].metrics[%v].%v", i, j, err)
+			}
+			existing = append(existing, m)
+			q.Mappings = append(q.Mappings, m)
+		}
+
+		queries = append(queries, q)
+	}
+	return queries, nil
+}
+
+// QueryMappings returns the mappings of all of the queries.
+func QueryMappings(queries []Query) []Mapping {
+	var mappings []Mapping
+	for _, q := range queries {
+		mappings = append(mappings, q.Mappings...)
+	}
+	return mappings
+}
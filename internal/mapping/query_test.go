@@ -0,0 +1,130 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQueries(t *testing.T) {
+	data := `
+queries:
+  - command: show stats_totals
+    metrics:
+      - column: total_xact_count
+        metric: pgbouncer_stats_totals_xact_count
+        type: counter
+  - command: SHOW STATS_AVERAGES
+    metrics:
+      - column: wait_time
+        metric: pgbouncer_stats_averages_wait_seconds
+        help: Average wait time in seconds.
+        scale: 0.000001
+        labels: [database]
+`
+
+	queries, err := ParseQueries([]byte(data))
+	require.NoError(t, err)
+	require.Equal(t, []Query{
+		{
+			Command: "SHOW STATS_TOTALS",
+			Mappings: []Mapping{
+				{
+					Command: "SHOW STATS_TOTALS",
+					Column:  "total_xact_count",
+					Metric:  "pgbouncer_stats_totals_xact_count",
+					Help:    "Value of the total_xact_count column returned by SHOW STATS_TOTALS.",
+					Type:    TypeCounter,
+					Scale:   1,
+				},
+			},
+		},
+		{
+			Command: "SHOW STATS_AVERAGES",
+			Mappings: []Mapping{
+				{
+					Command: "SHOW STATS_AVERAGES",
+					Column:  "wait_time",
+					Metric:  "pgbouncer_stats_averages_wait_seconds",
+					Help:    "Average wait time in seconds.",
+					Type:    TypeGauge,
+					Scale:   0.000001,
+					Labels:  []string{"database"},
+				},
+			},
+		},
+	}, queries)
+	require.Len(t, QueryMappings(queries), 2)
+}
+
+var (
+	parseQueriesErrorCases = []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "unknown key",
+			data: "queries:\n  - command: SHOW STATS_TOTALS\n    metric: []\n",
+			err:  "line 3: field metric not found",
+		},
+		{
+			name: "not a show command",
+			data: "queries:\n  - command: RELOAD\n    metrics:\n      - column: x\n        metric: x\n",
+			err:  `queries[0].command: must be a SHOW command, got "RELOAD"`,
+		},
+		{
+			name: "built-in command",
+			data: "queries:\n  - command: show pools\n    metrics:\n      - column: x\n        metric: x\n",
+			err:  "queries[0].command: SHOW POOLS is queried by the exporter, use the mappings file instead",
+		},
+		{
+			name: "totals command",
+			data: "queries:\n  - command: SHOW TOTALS\n    metrics:\n      - column: query_count\n        metric: x\n",
+			err:  "queries[0].command: SHOW TOTALS is queried by the exporter, use the mappings file instead",
+		},
+		{
+			name: "duplicate command",
+			data: "queries:\n  - command: SHOW STATS_TOTALS\n    metrics:\n      - column: x\n        metric: x\n  - command: SHOW STATS_TOTALS\n    metrics:\n      - column: y\n        metric: y\n",
+			err:  "queries[1].command: duplicate command SHOW STATS_TOTALS",
+		},
+		{
+			name: "missing metrics",
+			data: "queries:\n  - command: SHOW STATS_TOTALS\n",
+			err:  "queries[0].metrics: at least one metric must be defined",
+		},
+		{
+			name: "invalid metric",
+			data: "queries:\n  - command: SHOW STATS_TOTALS\n    metrics:\n      - column: x\n        metric: totals-x\n",
+			err:  `queries[0].metrics[0].metric: invalid metric name "totals-x"`,
+		},
+		{
+			name: "conflicting metric",
+			data: "queries:\n  - command: SHOW STATS_TOTALS\n    metrics:\n      - column: total_sent\n        metric: pgbouncer_exporter_stats_total_sent\n        labels: [database]\n",
+			err:  "queries[0].metrics[0].metric: pgbouncer_exporter_stats_total_sent is already defined with a different command, type, help or labels",
+		},
+	}
+)
+
+func TestParseQueriesErrors(t *testing.T) {
+	for _, cs := range parseQueriesErrorCases {
+		t.Run(cs.name, func(t *testing.T) {
+			_, err := ParseQueries([]byte(cs.data))
+			require.ErrorContains(t, err, cs.err)
+		})
+	}
+}
+
+func TestLoadQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.yml")
+	require.NoError(t, os.WriteFile(path, []byte("queries: []\n"), 0o600))
+
+	queries, err := LoadQueries(path)
+	require.NoError(t, err)
+	require.Empty(t, queries)
+
+	_, err = LoadQueries(filepath.Join(t.TempDir(), "missing.yml"))
+	require.ErrorContains(t, err, "could not read queries file")
+}
@@ -0,0 +1,42 @@
+// Package metricstest provides the metrics gathered by the tests of the metric senders.
+package metricstest
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics represents a registry with a pools gauge set to 12 and a totals counter set to 3100,
+// both labelled with instance="pg1" like the metrics of the exporter. The tests can change their values.
+type Metrics struct {
+	Registry      *prometheus.Registry
+	ActiveClients *prometheus.GaugeVec
+	Queries       prometheus.Counter
+}
+
+// NewMetrics returns new Metrics, the active clients are set for the given database.
+func NewMetrics(database string) *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		ActiveClients: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:        "pgbouncer_exporter_pools_active_clients",
+			Help:        "Client connections linked to server connection and able to process queries.",
+			ConstLabels: prometheus.Labels{"instance": "pg1"},
+		}, []string{"database"}),
+		Queries: NewQueriesCounter(),
+	}
+	m.ActiveClients.WithLabelValues(database).Set(12)
+	m.Queries.Add(3100)
+
+	m.Registry.MustRegister(m.ActiveClients, m.Queries)
+	return m
+}
+
+// NewQueriesCounter returns a new unregistered counter of the same series as Metrics.Queries,
+// the tests use it to replace the registered counter.
+func NewQueriesCounter() prometheus.Counter {
+	return prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "pgbouncer_exporter_totals_queries_total",
+		Help:        "Total number of SQL queries pooled by pgbouncer across all databases.",
+		ConstLabels: prometheus.Labels{"instance": "pg1"},
+	})
+}
@@ -0,0 +1,102 @@
+// Package otlp pushes the metrics gathered from a prometheus registry to an OpenTelemetry collector using OTLP.
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/collector"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promBridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// Protocols of the OTLP exporter.
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+// shutdownTimeout is the time given to the last push on shutdown.
+const shutdownTimeout = 5 * time.Second
+
+// Pusher periodically gathers the metrics and pushes them using OTLP, the names of the metrics
+// and their labels, converted to attributes, are the same as when scraped.
+type Pusher struct {
+	provider *sdkmetric.MeterProvider
+}
+
+// New returns a new Pusher of the metrics gathered by gatherer. The protocol, the endpoint, the export
+// interval and the resource are configured using the standard OTEL_* environment variables.
+func New(ctx context.Context, gatherer prometheus.Gatherer) (*Pusher, error) {
+	exporter, err := newExporter(ctx, Protocol())
+	if err != nil {
+		return nil, err
+	}
+
+	// the service name and the attributes from the environment take precedence over the defaults
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", collector.Name)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not create resource: %v", err)
+	}
+
+	producer := promBridge.NewMetricProducer(promBridge.WithGatherer(gatherer))
+	reader := sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithProducer(producer))
+
+	return &Pusher{
+		provider: sdkmetric.NewMeterProvider(
+			sdkmetric.WithReader(reader),
+			sdkmetric.WithResource(res),
+		),
+	}, nil
+}
+
+// Protocol returns the OTLP protocol set by OTEL_EXPORTER_OTLP_METRICS_PROTOCOL or
+// OTEL_EXPORTER_OTLP_PROTOCOL, it defaults to http/protobuf.
+func Protocol() string {
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_METRICS_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL"} {
+		if protocol := os.Getenv(name); protocol != "" {
+			return protocol
+		}
+	}
+	return ProtocolHTTP
+}
+
+func newExporter(ctx context.Context, protocol string) (sdkmetric.Exporter, error) {
+	var (
+		exporter sdkmetric.Exporter
+		err      error
+	)
+	switch protocol {
+	case ProtocolGRPC:
+		exporter, err = otlpmetricgrpc.New(ctx)
+	case ProtocolHTTP:
+		exporter, err = otlpmetrichttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q, must be one of %v, %v", protocol, ProtocolGRPC, ProtocolHTTP)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not create OTLP exporter: %v", err)
+	}
+	return exporter, nil
+}
+
+// Run pushes the metrics until ctx is done, then the metrics are pushed for the last time.
+func (p *Pusher) Run(ctx context.Context) error {
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return p.provider.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,84 @@
+package otlp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/metricstest"
+	"github.com/jbub/pgbouncer_exporter/internal/otlptest"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+var (
+	pusherCases = []struct {
+		protocol string
+		endpoint func(r *otlptest.Receiver) string
+	}{
+		{
+			protocol: ProtocolGRPC,
+			endpoint: (*otlptest.Receiver).GRPCEndpoint,
+		},
+		{
+			protocol: ProtocolHTTP,
+			endpoint: (*otlptest.Receiver).HTTPEndpoint,
+		},
+	}
+)
+
+func TestPusher(t *testing.T) {
+	for _, cs := range pusherCases {
+		t.Run(cs.protocol, func(t *testing.T) {
+			receiver := otlptest.NewReceiver()
+			defer receiver.Close() //nolint:errcheck
+
+			t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", cs.protocol)
+			t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", cs.endpoint(receiver))
+			t.Setenv("OTEL_METRIC_EXPORT_INTERVAL", "20")
+			t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=test")
+
+			pusher, err := New(context.Background(), metricstest.NewMetrics("app").Registry)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errc := make(chan error, 1)
+			go func() {
+				errc <- pusher.Run(ctx)
+			}()
+
+			require.Eventually(t, func() bool {
+				return receiver.Requests() > 0
+			}, 5*time.Second, 10*time.Millisecond)
+
+			cancel()
+			require.NoError(t, <-errc)
+
+			require.Equal(t, "pgbouncer_exporter", receiver.Resource()["service.name"])
+			require.Equal(t, "test", receiver.Resource()["deployment.environment"])
+
+			gauge, ok := receiver.Metric("pgbouncer_exporter_pools_active_clients")
+			require.True(t, ok)
+			require.Len(t, gauge.GetGauge().GetDataPoints(), 1)
+			point := gauge.GetGauge().GetDataPoints()[0]
+			require.Equal(t, 12.0, point.GetAsDouble())
+			require.Equal(t, map[string]string{"database": "app", "instance": "pg1"}, otlptest.Attributes(point.GetAttributes()))
+
+			counter, ok := receiver.Metric("pgbouncer_exporter_totals_queries_total")
+			require.True(t, ok)
+			require.True(t, counter.GetSum().GetIsMonotonic())
+			require.Equal(t, metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, counter.GetSum().GetAggregationTemporality())
+			require.Equal(t, 3100.0, counter.GetSum().GetDataPoints()[0].GetAsDouble())
+		})
+	}
+}
+
+func TestUnsupportedProtocol(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_PROTOCOL", "http/json")
+
+	_, err := New(context.Background(), prometheus.NewRegistry())
+	require.EqualError(t, err, `unsupported OTLP protocol "http/json", must be one of grpc, http/protobuf`)
+}
@@ -0,0 +1,155 @@
+// Package otlptest provides an in-process OTLP metrics receiver for tests.
+package otlptest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// Receiver accepts OTLP metrics exports both over gRPC and over HTTP using protobuf encoding.
+type Receiver struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+
+	httpSrv  *httptest.Server
+	grpcSrv  *grpc.Server
+	listener net.Listener
+
+	mut      sync.Mutex
+	requests []*colmetricpb.ExportMetricsServiceRequest
+}
+
+// NewReceiver starts and returns a new Receiver listening on random local ports.
+// It panics when it can not listen, the caller should call Close when finished.
+func NewReceiver() *Receiver {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("otlptest: could not listen: %v", err))
+	}
+
+	r := &Receiver{
+		grpcSrv:  grpc.NewServer(),
+		listener: listener,
+	}
+	colmetricpb.RegisterMetricsServiceServer(r.grpcSrv, r)
+	go r.grpcSrv.Serve(listener) //nolint:errcheck
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/metrics", r.serveHTTP)
+	r.httpSrv = httptest.NewServer(mux)
+	return r
+}
+
+// GRPCEndpoint returns the endpoint of the gRPC receiver.
+func (r *Receiver) GRPCEndpoint() string {
+	return "http://" + r.listener.Addr().String()
+}
+
+// HTTPEndpoint returns the endpoint of the HTTP receiver.
+func (r *Receiver) HTTPEndpoint() string {
+	return r.httpSrv.URL
+}
+
+// Export implements the gRPC metrics service.
+func (r *Receiver) Export(_ context.Context, req *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	r.add(req)
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+func (r *Receiver) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var export colmetricpb.ExportMetricsServiceRequest
+	if err := proto.Unmarshal(body, &export); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.add(&export)
+
+	resp, err := proto.Marshal(&colmetricpb.ExportMetricsServiceResponse{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(resp)
+}
+
+func (r *Receiver) add(req *colmetricpb.ExportMetricsServiceRequest) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.requests = append(r.requests, req)
+}
+
+// Requests returns the number of received export requests.
+func (r *Receiver) Requests() int {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return len(r.requests)
+}
+
+// Metric returns the metric of the last export request containing it.
+func (r *Receiver) Metric(name string) (*metricpb.Metric, bool) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	for i := len(r.requests) - 1; i >= 0; i-- {
+		for _, rm := range r.requests[i].GetResourceMetrics() {
+			for _, sm := range rm.GetScopeMetrics() {
+				for _, m := range sm.GetMetrics() {
+					if m.GetName() == name {
+						return m, true
+					}
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// Resource returns the attributes of the resource of the last export request.
+func (r *Receiver) Resource() map[string]string {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if len(r.requests) == 0 {
+		return nil
+	}
+	res := make(map[string]string)
+	for _, rm := range r.requests[len(r.requests)-1].GetResourceMetrics() {
+		for k, v := range Attributes(rm.GetResource().GetAttributes()) {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// Attributes returns the string values of the attributes.
+func Attributes(attrs []*commonpb.KeyValue) map[string]string {
+	res := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		res[kv.GetKey()] = kv.GetValue().GetStringValue()
+	}
+	return res
+}
+
+// Close stops both of the receivers.
+func (r *Receiver) Close() error {
+	r.httpSrv.Close()
+	r.grpcSrv.Stop()
+	return nil
+}
@@ -0,0 +1,155 @@
+package pgbouncertest
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed testdata/*.yml
+var fixtures embed.FS
+
+// Column types supported in fixtures, they match the types used by the pgbouncer admin console.
+const (
+	TypeText    = "text"
+	TypeInt4    = "int4"
+	TypeInt8    = "int8"
+	TypeNumeric = "numeric"
+)
+
+// Column represents a column of the result of an admin console command.
+type Column struct {
+	Name string
+	Type string
+}
+
+// Result represents the result of an admin console command, values are in the text format, nil represents NULL.
+type Result struct {
+	Columns []Column
+	Rows    [][]*string
+}
+
+// Fixture represents results of the admin console commands recorded from a single pgbouncer version.
+type Fixture struct {
+	Version string
+	// Results are keyed by the upper case command, for example SHOW STATS.
+	Results map[string]Result
+}
+
+type fileFixture struct {
+	Version  string `yaml:"version"`
+	Commands map[string]struct {
+		Columns []string `yaml:"columns"`
+		Rows    [][]any  `yaml:"rows"`
+	} `yaml:"commands"`
+}
+
+// Versions returns the pgbouncer versions of the available fixtures in ascending order.
+func Versions() []string {
+	entries, err := fixtures.ReadDir("testdata")
+	if err != nil {
+		panic(err)
+	}
+
+	versions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		versions = append(versions, strings.TrimSuffix(entry.Name(), ".yml"))
+	}
+	slices.SortFunc(versions, compareVersions)
+	return versions
+}
+
+// LoadFixture loads the fixture of the given pgbouncer version.
+func LoadFixture(version string) (*Fixture, error) {
+	data, err := fixtures.ReadFile(path.Join("testdata", version+".yml"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read fixture: %v", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var file fileFixture
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("could not parse fixture %v: %v", version, err)
+	}
+
+	fixture := &Fixture{
+		Version: file.Version,
+		Results: make(map[string]Result, len(file.Commands)),
+	}
+	for command, fc := range file.Commands {
+		res, err := parseResult(fc.Columns, fc.Rows)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fixture %v: %v: %v", version, command, err)
+		}
+		fixture.Results[normalizeCommand(command)] = res
+	}
+	return fixture, nil
+}
+
+func parseResult(columns []string, rows [][]any) (Result, error) {
+	var res Result
+	for _, column := range columns {
+		name, typ, ok := strings.Cut(column, " ")
+		if !ok {
+			return Result{}, fmt.Errorf("invalid column %q, expected name and type", column)
+		}
+		switch typ {
+		case TypeText, TypeInt4, TypeInt8, TypeNumeric:
+		default:
+			return Result{}, fmt.Errorf("unsupported type %q of column %v", typ, name)
+		}
+		res.Columns = append(res.Columns, Column{Name: name, Type: typ})
+	}
+
+	for i, row := range rows {
+		if len(row) != len(res.Columns) {
+			return Result{}, fmt.Errorf("row %v has %v values, expected %v", i, len(row), len(res.Columns))
+		}
+		values := make([]*string, 0, len(row))
+		for _, v := range row {
+			values = append(values, textValue(v))
+		}
+		res.Rows = append(res.Rows, values)
+	}
+	return res, nil
+}
+
+func textValue(v any) *string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case int:
+		s = strconv.Itoa(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+	return &s
+}
+
+func normalizeCommand(command string) string {
+	command = strings.TrimSpace(command)
+	command = strings.TrimSuffix(command, ";")
+	return strings.ToUpper(strings.Join(strings.Fields(command), " "))
+}
+
+// compareVersions compares dotted versions numerically, so that 1.9 sorts before 1.18.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, _ := strconv.Atoi(as[i])
+		bn, _ := strconv.Atoi(bs[i])
+		if an != bn {
+			return an - bn
+		}
+	}
+	return len(as) - len(bs)
+}
@@ -0,0 +1,235 @@
+// Package pgbouncertest provides a fake pgbouncer admin console for tests.
+package pgbouncertest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Server is a fake pgbouncer admin console speaking enough of the postgres wire protocol to answer
+// the commands of a Fixture. Like pgbouncer it supports only the simple query protocol.
+type Server struct {
+	fixture  *Fixture
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	connects        atomic.Int64
+	closeAfterQuery atomic.Bool
+
+	mut   sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// NewServer starts and returns a new Server listening on a random local port.
+// It panics when it can not listen, the caller should call Close when finished.
+func NewServer(fixture *Fixture) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("pgbouncertest: could not listen: %v", err))
+	}
+
+	srv := &Server{
+		fixture:  fixture,
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	srv.wg.Add(1)
+	go srv.serve()
+	return srv
+}
+
+// URL returns the connection url of the admin console.
+func (s *Server) URL() string {
+	return fmt.Sprintf("postgres://pgbouncer@%v/pgbouncer?sslmode=disable", s.listener.Addr())
+}
+
+// Connects returns the number of established client connections, cancel requests are not counted.
+func (s *Server) Connects() int64 {
+	return s.connects.Load()
+}
+
+// SetCloseAfterQuery makes the server close the client connection after answering each query.
+func (s *Server) SetCloseAfterQuery(v bool) {
+	s.closeAfterQuery.Store(v)
+}
+
+// Close stops the server and closes all client connections.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	s.mut.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mut.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mut.Lock()
+		s.conns[conn] = struct{}{}
+		s.mut.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+
+			s.mut.Lock()
+			delete(s.conns, conn)
+			s.mut.Unlock()
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close() //nolint:errcheck
+
+	backend := pgproto3.NewBackend(conn, conn)
+	if err := s.startup(conn, backend); err != nil {
+		return
+	}
+	s.connects.Add(1)
+
+	// extended query errors are reported once, the rest of the messages is skipped until Sync
+	var failed bool
+
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			s.respond(backend, msg.String)
+		case *pgproto3.Sync:
+			failed = false
+		case *pgproto3.Terminate:
+			return
+		case *pgproto3.Parse, *pgproto3.Bind, *pgproto3.Describe, *pgproto3.Execute, *pgproto3.Close, *pgproto3.Flush:
+			if !failed {
+				backend.Send(errorResponse("extended query protocol not supported by admin console"))
+				failed = true
+			}
+			if err := backend.Flush(); err != nil {
+				return
+			}
+			continue
+		default:
+			backend.Send(errorResponse(fmt.Sprintf("unsupported message %T", msg)))
+		}
+
+		backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+		if err := backend.Flush(); err != nil || s.closeAfterQuery.Load() {
+			return
+		}
+	}
+}
+
+func (s *Server) startup(conn net.Conn, backend *pgproto3.Backend) error {
+	for {
+		msg, err := backend.ReceiveStartupMessage()
+		if err != nil {
+			return err
+		}
+
+		switch msg.(type) {
+		case *pgproto3.SSLRequest, *pgproto3.GSSEncRequest:
+			if _, err := conn.Write([]byte{'N'}); err != nil {
+				return err
+			}
+			continue
+		case *pgproto3.StartupMessage:
+		default:
+			// cancel requests are sent using separate connections and there is nothing to cancel
+			return errors.New("not a startup message")
+		}
+
+		backend.Send(&pgproto3.AuthenticationOk{})
+		// parameters sent by the admin console of pgbouncer
+		backend.Send(&pgproto3.ParameterStatus{Name: "server_version", Value: s.fixture.Version + "/bouncer"})
+		backend.Send(&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"})
+		backend.Send(&pgproto3.ParameterStatus{Name: "server_encoding", Value: "UTF8"})
+		backend.Send(&pgproto3.ParameterStatus{Name: "DateStyle", Value: "ISO"})
+		backend.Send(&pgproto3.ParameterStatus{Name: "TimeZone", Value: "GMT"})
+		backend.Send(&pgproto3.ParameterStatus{Name: "standard_conforming_strings", Value: "on"})
+		backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: []byte{0, 0, 0, 1}})
+		backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+		return backend.Flush()
+	}
+}
+
+func (s *Server) respond(backend *pgproto3.Backend, query string) {
+	command := normalizeCommand(query)
+	res, ok := s.fixture.Results[command]
+	if !ok {
+		backend.Send(errorResponse(fmt.Sprintf("invalid command '%v', use SHOW HELP;", query)))
+		return
+	}
+
+	fields := make([]pgproto3.FieldDescription, 0, len(res.Columns))
+	for _, column := range res.Columns {
+		fields = append(fields, fieldDescription(column))
+	}
+	backend.Send(&pgproto3.RowDescription{Fields: fields})
+
+	for _, row := range res.Rows {
+		values := make([][]byte, 0, len(row))
+		for _, v := range row {
+			if v == nil {
+				values = append(values, nil)
+				continue
+			}
+			values = append(values, []byte(*v))
+		}
+		backend.Send(&pgproto3.DataRow{Values: values})
+	}
+	backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("SHOW")})
+}
+
+func fieldDescription(column Column) pgproto3.FieldDescription {
+	fd := pgproto3.FieldDescription{
+		Name:         []byte(column.Name),
+		DataTypeSize: -1,
+		TypeModifier: -1,
+	}
+	switch column.Type {
+	case TypeText:
+		fd.DataTypeOID = pgtype.TextOID
+	case TypeInt4:
+		fd.DataTypeOID = pgtype.Int4OID
+		fd.DataTypeSize = 4
+	case TypeInt8:
+		fd.DataTypeOID = pgtype.Int8OID
+		fd.DataTypeSize = 8
+	case TypeNumeric:
+		fd.DataTypeOID = pgtype.NumericOID
+	}
+	return fd
+}
+
+func errorResponse(message string) *pgproto3.ErrorResponse {
+	return &pgproto3.ErrorResponse{
+		Severity:            "ERROR",
+		SeverityUnlocalized: "ERROR",
+		Code:                "08P01",
+		Message:             message,
+	}
+}
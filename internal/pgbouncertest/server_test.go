@@ -0,0 +1,70 @@
+package pgbouncertest
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func openDB(t *testing.T, srv *Server) *sql.DB {
+	connector, err := pq.NewConnector(srv.URL())
+	require.NoError(t, err)
+
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestServer(t *testing.T) {
+	fixture, err := LoadFixture("1.24")
+	require.NoError(t, err)
+
+	srv := NewServer(fixture)
+	defer srv.Close() //nolint:errcheck
+
+	db := openDB(t, srv)
+	ctx := context.Background()
+
+	var version string
+	require.NoError(t, db.QueryRowContext(ctx, "show version;").Scan(&version))
+	require.Equal(t, "PgBouncer 1.24.1", version)
+
+	var host sql.NullString
+	var port int64
+	require.NoError(t, db.QueryRowContext(ctx, "SHOW DATABASES").Scan(new(string), &host, &port, new(string),
+		new(sql.NullString), new(int64), new(int64), new(int64), new(int64), new(string), new(sql.NullString),
+		new(int64), new(int64), new(int64), new(int64), new(int64), new(int64)))
+	require.Equal(t, "db.internal", host.String)
+	require.Equal(t, int64(5432), port)
+
+	_, err = db.ExecContext(ctx, "SHOW FOO")
+	require.EqualError(t, err, `pq: invalid command 'SHOW FOO', use SHOW HELP; (08P01)`)
+}
+
+func TestServerRejectsExtendedQueries(t *testing.T) {
+	fixture, err := LoadFixture("1.24")
+	require.NoError(t, err)
+
+	srv := NewServer(fixture)
+	defer srv.Close() //nolint:errcheck
+
+	db := openDB(t, srv)
+
+	_, err = db.QueryContext(context.Background(), "SHOW STATS", pq.Array([]string{}))
+	require.ErrorContains(t, err, "extended query protocol not supported by admin console")
+
+	// the connection stays usable
+	var version string
+	require.NoError(t, db.QueryRowContext(context.Background(), "SHOW VERSION").Scan(&version))
+}
+
+func TestVersions(t *testing.T) {
+	versions := []string{"1.24", "1.9", "1.18", "1.18.1"}
+	slices.SortFunc(versions, compareVersions)
+	require.Equal(t, []string{"1.9", "1.18", "1.18.1", "1.24"}, versions)
+	require.Contains(t, Versions(), "1.24")
+}
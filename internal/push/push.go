@@ -0,0 +1,160 @@
+// Package push periodically pushes the metrics gathered from a prometheus registry to a Pushgateway
+// or to a Prometheus remote write endpoint.
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	pushgateway "github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Modes of the Pusher.
+const (
+	// ModePushgateway replaces the metrics of the grouping key in a Pushgateway.
+	ModePushgateway = "pushgateway"
+	// ModeRemoteWrite sends the metrics to a Prometheus remote write endpoint.
+	ModeRemoteWrite = "remote-write"
+)
+
+// Options represents the configuration of the Pusher.
+type Options struct {
+	// Mode is one of ModePushgateway or ModeRemoteWrite.
+	Mode string
+	// URL is the base url of the Pushgateway or the url of the remote write endpoint.
+	URL string
+	// Job is the job of the grouping key, it is added as the job label in the remote write mode.
+	Job string
+	// Grouping are the labels of the grouping key, they are added to all series in the remote write mode.
+	Grouping map[string]string
+	// Interval between the pushes, when zero the metrics are pushed only once.
+	Interval time.Duration
+	// Retries is the number of times a failed push is retried.
+	Retries int
+	// RetryInterval is the time waited before a failed push is retried.
+	RetryInterval time.Duration
+	// Timeout of a single push request.
+	Timeout time.Duration
+}
+
+// Validate validates the options.
+func (o Options) Validate() error {
+	if o.URL == "" {
+		return errors.New("push url must not be empty")
+	}
+	if o.Mode != ModePushgateway && o.Mode != ModeRemoteWrite {
+		return fmt.Errorf("unsupported push mode %q, must be one of %v, %v", o.Mode, ModePushgateway, ModeRemoteWrite)
+	}
+	if o.Job == "" {
+		return errors.New("push job must not be empty")
+	}
+	if o.Interval < 0 {
+		return errors.New("push interval must not be negative")
+	}
+	if o.Retries < 0 {
+		return errors.New("push retries must not be negative")
+	}
+	if o.Timeout <= 0 {
+		return errors.New("push timeout must be positive")
+	}
+	return nil
+}
+
+// Pusher pushes the metrics gathered by a prometheus.Gatherer.
+type Pusher struct {
+	opts   Options
+	client *http.Client
+	push   func(ctx context.Context) error
+}
+
+// New returns a new Pusher of the metrics gathered by gatherer.
+func New(opts Options, gatherer prometheus.Gatherer) (*Pusher, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := &Pusher{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+	}
+
+	switch opts.Mode {
+	case ModePushgateway:
+		pusher := pushgateway.New(opts.URL, opts.Job).Gatherer(gatherer).Client(p.client)
+		for name, value := range opts.Grouping {
+			pusher = pusher.Grouping(name, value)
+		}
+		p.push = pusher.PushContext
+	case ModeRemoteWrite:
+		writer := &remoteWriter{
+			url:      opts.URL,
+			client:   p.client,
+			gatherer: gatherer,
+			labels:   externalLabels(opts.Job, opts.Grouping),
+		}
+		p.push = writer.write
+	}
+	return p, nil
+}
+
+// Run pushes the metrics every interval until ctx is done, failed pushes are logged.
+// When the interval is zero the metrics are pushed only once and the error of the push is returned.
+func (p *Pusher) Run(ctx context.Context) error {
+	if p.opts.Interval == 0 {
+		return p.Push(ctx)
+	}
+
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Push(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("could not push metrics: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Push pushes the metrics, failed pushes are retried unless the error is permanent.
+func (p *Pusher) Push(ctx context.Context) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = p.push(ctx)
+		if err == nil || attempt == p.opts.Retries || isPermanent(err) || ctx.Err() != nil {
+			break
+		}
+		log.Printf("could not push metrics, retrying in %v: %v", p.opts.RetryInterval, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.opts.RetryInterval):
+		}
+	}
+	return err
+}
+
+// permanentError is returned when retrying the push would not help, for example when
+// the remote write endpoint rejects the request as invalid.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func isPermanent(err error) bool {
+	var perr permanentError
+	return errors.As(err, &perr)
+}
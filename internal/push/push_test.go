@@ -0,0 +1,275 @@
+package push
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/metricstest"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRegistry returns the registry of the test metrics with a histogram added.
+func newTestRegistry() *prometheus.Registry {
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "pgbouncer_exporter_test_seconds",
+		Help:    "Test histogram.",
+		Buckets: []float64{0.5},
+	})
+	histogram.Observe(0.25)
+	histogram.Observe(1)
+
+	reg := metricstest.NewMetrics("app").Registry
+	reg.MustRegister(histogram)
+	return reg
+}
+
+// recorder is an http handler recording the requests, it responds with the given status codes
+// in order, the last one is used for the remaining requests.
+type recorder struct {
+	mut      sync.Mutex
+	statuses []int
+	requests []recordedRequest
+}
+
+type recordedRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	r.requests = append(r.requests, recordedRequest{
+		method: req.Method,
+		path:   req.URL.EscapedPath(),
+		header: req.Header.Clone(),
+		body:   body,
+	})
+	status := r.statuses[min(len(r.requests), len(r.statuses))-1]
+	w.WriteHeader(status)
+}
+
+func (r *recorder) recorded() []recordedRequest {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return append([]recordedRequest(nil), r.requests...)
+}
+
+func newOptions(mode, url string) Options {
+	return Options{
+		Mode:          mode,
+		URL:           url,
+		Job:           "ci",
+		Grouping:      map[string]string{"env": "test 1"},
+		Retries:       2,
+		RetryInterval: time.Millisecond,
+		Timeout:       time.Second,
+	}
+}
+
+func TestPushgateway(t *testing.T) {
+	rec := &recorder{statuses: []int{http.StatusOK}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	pusher, err := New(newOptions(ModePushgateway, srv.URL), newTestRegistry())
+	require.NoError(t, err)
+	require.NoError(t, pusher.Run(context.Background()))
+
+	reqs := rec.recorded()
+	require.Len(t, reqs, 1)
+	require.Equal(t, http.MethodPut, reqs[0].method)
+	require.Equal(t, "/metrics/job/ci/env/test+1", reqs[0].path)
+	require.Contains(t, string(reqs[0].body), "pgbouncer_exporter_pools_active_clients")
+}
+
+func TestRemoteWrite(t *testing.T) {
+	rec := &recorder{statuses: []int{http.StatusNoContent}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	pusher, err := New(newOptions(ModeRemoteWrite, srv.URL+"/api/v1/write"), newTestRegistry())
+	require.NoError(t, err)
+	require.NoError(t, pusher.Run(context.Background()))
+
+	reqs := rec.recorded()
+	require.Len(t, reqs, 1)
+	require.Equal(t, http.MethodPost, reqs[0].method)
+	require.Equal(t, "/api/v1/write", reqs[0].path)
+	require.Equal(t, "snappy", reqs[0].header.Get("Content-Encoding"))
+	require.Equal(t, "application/x-protobuf", reqs[0].header.Get("Content-Type"))
+	require.Equal(t, "0.1.0", reqs[0].header.Get("X-Prometheus-Remote-Write-Version"))
+
+	body, err := snappy.Decode(nil, reqs[0].body)
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{
+		`pgbouncer_exporter_pools_active_clients{database="app",env="test 1",instance="pg1",job="ci"}`: 12,
+		`pgbouncer_exporter_totals_queries_total{env="test 1",instance="pg1",job="ci"}`:                3100,
+		`pgbouncer_exporter_test_seconds_bucket{env="test 1",job="ci",le="0.5"}`:                       1,
+		`pgbouncer_exporter_test_seconds_bucket{env="test 1",job="ci",le="+Inf"}`:                      2,
+		`pgbouncer_exporter_test_seconds_sum{env="test 1",job="ci"}`:                                   1.25,
+		`pgbouncer_exporter_test_seconds_count{env="test 1",job="ci"}`:                                 2,
+	}, decodeWriteRequest(t, body))
+}
+
+var (
+	retryCases = []struct {
+		name     string
+		mode     string
+		statuses []int
+		requests int
+		err      bool
+	}{
+		{
+			name:     "pushgateway recovers",
+			mode:     ModePushgateway,
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			requests: 2,
+		},
+		{
+			name:     "pushgateway retries exhausted",
+			mode:     ModePushgateway,
+			statuses: []int{http.StatusServiceUnavailable},
+			requests: 3,
+			err:      true,
+		},
+		{
+			name:     "remote write rate limited",
+			mode:     ModeRemoteWrite,
+			statuses: []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusNoContent},
+			requests: 3,
+		},
+		{
+			name:     "remote write rejected",
+			mode:     ModeRemoteWrite,
+			statuses: []int{http.StatusBadRequest},
+			requests: 1,
+			err:      true,
+		},
+	}
+)
+
+func TestRetries(t *testing.T) {
+	for _, cs := range retryCases {
+		t.Run(cs.name, func(t *testing.T) {
+			rec := &recorder{statuses: cs.statuses}
+			srv := httptest.NewServer(rec)
+			defer srv.Close()
+
+			pusher, err := New(newOptions(cs.mode, srv.URL), newTestRegistry())
+			require.NoError(t, err)
+
+			err = pusher.Push(context.Background())
+			if cs.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Len(t, rec.recorded(), cs.requests)
+		})
+	}
+}
+
+func TestRunInterval(t *testing.T) {
+	rec := &recorder{statuses: []int{http.StatusOK}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	opts := newOptions(ModePushgateway, srv.URL)
+	opts.Interval = 10 * time.Millisecond
+
+	pusher, err := New(opts, newTestRegistry())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- pusher.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(rec.recorded()) >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-errc)
+}
+
+var (
+	optionsCases = []struct {
+		name   string
+		modify func(o *Options)
+		err    string
+	}{
+		{
+			name:   "missing url",
+			modify: func(o *Options) { o.URL = "" },
+			err:    "push url must not be empty",
+		},
+		{
+			name:   "unsupported mode",
+			modify: func(o *Options) { o.Mode = "graphite" },
+			err:    `unsupported push mode "graphite", must be one of pushgateway, remote-write`,
+		},
+		{
+			name:   "missing job",
+			modify: func(o *Options) { o.Job = "" },
+			err:    "push job must not be empty",
+		},
+		{
+			name:   "negative retries",
+			modify: func(o *Options) { o.Retries = -1 },
+			err:    "push retries must not be negative",
+		},
+	}
+)
+
+func TestOptionsValidate(t *testing.T) {
+	for _, cs := range optionsCases {
+		t.Run(cs.name, func(t *testing.T) {
+			opts := newOptions(ModePushgateway, "http://localhost:9091")
+			cs.modify(&opts)
+			require.EqualError(t, opts.Validate(), cs.err)
+		})
+	}
+}
+
+// decodeWriteRequest decodes the prompb.WriteRequest message and returns the values
+// of its series keyed by their labels in the text format.
+func decodeWriteRequest(t *testing.T, b []byte) map[string]float64 {
+	var wr prompb.WriteRequest
+	require.NoError(t, wr.Unmarshal(b))
+
+	res := make(map[string]float64)
+	for _, ts := range wr.Timeseries {
+		var (
+			name   string
+			labels []string
+		)
+		for _, l := range ts.Labels {
+			if l.Name == "__name__" {
+				name = l.Value
+				continue
+			}
+			labels = append(labels, l.Name+`="`+l.Value+`"`)
+		}
+		require.Len(t, ts.Samples, 1)
+		res[name+"{"+strings.Join(labels, ",")+"}"] = ts.Samples[0].Value
+	}
+	return res
+}
@@ -0,0 +1,163 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// remoteWriteVersion is the version of the remote write protocol sent in the request header.
+const remoteWriteVersion = "0.1.0"
+
+// remoteWriter sends the gathered metrics using version 1 of the Prometheus remote write protocol.
+type remoteWriter struct {
+	url      string
+	client   *http.Client
+	gatherer prometheus.Gatherer
+	labels   []prompb.Label
+}
+
+// externalLabels returns the labels added to all series, sorted by name.
+func externalLabels(job string, grouping map[string]string) []prompb.Label {
+	labels := []prompb.Label{{Name: "job", Value: job}}
+	for _, name := range slices.Sorted(maps.Keys(grouping)) {
+		if name == "job" {
+			continue
+		}
+		labels = append(labels, prompb.Label{Name: name, Value: grouping[name]})
+	}
+	slices.SortFunc(labels, compareLabels)
+	return labels
+}
+
+func (w *remoteWriter) write(ctx context.Context) error {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("could not gather metrics: %v", err)
+	}
+
+	wr := &prompb.WriteRequest{Timeseries: w.series(families, time.Now())}
+	data, err := wr.Marshal()
+	if err != nil {
+		return permanentError{err: fmt.Errorf("could not encode write request: %v", err)}
+	}
+	body := snappy.Encode(nil, data)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err: fmt.Errorf("could not create request: %v", err)}
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", remoteWriteVersion)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("unexpected status code %v while writing to %v: %s", resp.StatusCode, w.url, bytes.TrimSpace(msg))
+	// client errors other than rate limiting are not retried, as required by the protocol
+	if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+		return permanentError{err: err}
+	}
+	return err
+}
+
+// series converts the metric families to series, summaries and histograms are split
+// into the series of their quantiles or buckets, sum and count.
+func (w *remoteWriter) series(families []*dto.MetricFamily, now time.Time) []prompb.TimeSeries {
+	var res []prompb.TimeSeries
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			ts := now.UnixMilli()
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+			add := func(name string, value float64, extra ...prompb.Label) {
+				res = append(res, prompb.TimeSeries{
+					Labels:  w.seriesLabels(name, m.GetLabel(), extra...),
+					Samples: []prompb.Sample{{Value: value, Timestamp: ts}},
+				})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				summary := m.GetSummary()
+				for _, q := range summary.GetQuantile() {
+					add(name, q.GetValue(), prompb.Label{Name: "quantile", Value: formatFloat(q.GetQuantile())})
+				}
+				add(name+"_sum", summary.GetSampleSum())
+				add(name+"_count", float64(summary.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				histogram := m.GetHistogram()
+				infSeen := false
+				for _, b := range histogram.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), 1) {
+						infSeen = true
+					}
+					add(name+"_bucket", float64(b.GetCumulativeCount()), prompb.Label{Name: "le", Value: formatFloat(b.GetUpperBound())})
+				}
+				if !infSeen {
+					add(name+"_bucket", float64(histogram.GetSampleCount()), prompb.Label{Name: "le", Value: "+Inf"})
+				}
+				add(name+"_sum", histogram.GetSampleSum())
+				add(name+"_count", float64(histogram.GetSampleCount()))
+			}
+		}
+	}
+	return res
+}
+
+// seriesLabels returns the sorted labels of a series, the labels of the metric take precedence
+// over the external labels.
+func (w *remoteWriter) seriesLabels(name string, pairs []*dto.LabelPair, extra ...prompb.Label) []prompb.Label {
+	labels := make([]prompb.Label, 0, len(pairs)+len(extra)+len(w.labels)+1)
+	labels = append(labels, prompb.Label{Name: "__name__", Value: name})
+	for _, pair := range pairs {
+		labels = append(labels, prompb.Label{Name: pair.GetName(), Value: pair.GetValue()})
+	}
+	labels = append(labels, extra...)
+	for _, l := range w.labels {
+		if !slices.ContainsFunc(labels, func(o prompb.Label) bool { return o.Name == l.Name }) {
+			labels = append(labels, l)
+		}
+	}
+	slices.SortFunc(labels, compareLabels)
+	return labels
+}
+
+func compareLabels(a, b prompb.Label) int {
+	return strings.Compare(a.Name, b.Name)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
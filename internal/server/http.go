@@ -1,6 +1,9 @@
 package server
 
 import (
+	"context"
+	"fmt"
+	"log"
 	"net/http"
 	"time"
 
@@ -8,10 +11,13 @@ import (
 	"github.com/jbub/pgbouncer_exporter/internal/config"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func getLandingPage(telemetryPath string) []byte {
+func getLandingPage(telemetryPath string, snapshot bool) []byte {
+	var snapshotLink string
+	if snapshot {
+		snapshotLink = `<p><a href="` + snapshotPath + `">Snapshot</a></p>`
+	}
 	return []byte(`
 	<html>
 	<head>
@@ -20,14 +26,30 @@ func getLandingPage(telemetryPath string) []byte {
 	<body>
 	<h1>` + collector.Name + `</h1>
 	<p><a href="` + telemetryPath + `">Metrics</a></p>
+	` + snapshotLink + `
 	</body>
 	</html>`)
 }
 
-// New returns new prometheus exporter http server.
-func New(cfg config.Config, exp *collector.Exporter) *HTTPServer {
-	reg := collector.NewRegistry(exp)
-	mux := newHTTPMux(reg, cfg.TelemetryPath)
+// New returns new prometheus exporter http server, reloader is optional. The /-/reload endpoint
+// is served only when the lifecycle endpoints are enabled in cfg, the snapshot endpoint only
+// when it is enabled in cfg.
+func New(cfg config.Config, reloader *collector.Reloader, exps ...*collector.Exporter) *HTTPServer {
+	var (
+		collectors []prometheus.Collector
+		reload     func() error
+	)
+	if reloader != nil {
+		collectors = append(collectors, reloader)
+		if cfg.EnableLifecycle {
+			reload = reloader.Reload
+		}
+	}
+
+	reg := collector.NewRegistry(exps...)
+	reg.MustRegister(collectors...)
+
+	mux := newHTTPMux(reg, exps, collectors, cfg.TelemetryPath, reload, cfg.EnableSnapshot)
 	srv := newHTTPServer(cfg.ListenAddress, mux)
 	return &HTTPServer{
 		srv: srv,
@@ -45,11 +67,29 @@ func newHTTPServer(listenAddr string, handler http.Handler) *http.Server {
 	}
 }
 
-func newHTTPMux(reg prometheus.Gatherer, telemetryPath string) *http.ServeMux {
+func newHTTPMux(reg prometheus.Gatherer, exps []*collector.Exporter, collectors []prometheus.Collector, telemetryPath string, reload func() error, snapshot bool) *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.Handle(telemetryPath, promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
-	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
-		_, _ = w.Write(getLandingPage(telemetryPath))
+	mux.Handle(telemetryPath, newTelemetryHandler(reg, exps, collectors))
+	if snapshot {
+		mux.Handle(snapshotPath, &snapshotHandler{exps: exps})
+	}
+	if reload != nil {
+		mux.HandleFunc("/-/reload", func(w http.ResponseWriter, req *http.Request) {
+			if req.Method != http.MethodPost {
+				w.Header().Set("Allow", http.MethodPost)
+				http.Error(w, "only POST requests allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if err := reload(); err != nil {
+				log.Printf("could not reload config: %v", err)
+				http.Error(w, fmt.Sprintf("could not reload config: %v", err), http.StatusInternalServerError)
+				return
+			}
+			_, _ = w.Write([]byte("OK"))
+		})
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(getLandingPage(telemetryPath, snapshot))
 	})
 	return mux
 }
@@ -59,7 +99,23 @@ type HTTPServer struct {
 	srv *http.Server
 }
 
-// Run runs http server.
-func (s *HTTPServer) Run() error {
-	return s.srv.ListenAndServe()
+// shutdownTimeout is the time given to requests in flight to finish on shutdown.
+const shutdownTimeout = 5 * time.Second
+
+// Run runs http server until ctx is done, then it is shut down gracefully.
+func (s *HTTPServer) Run(ctx context.Context) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.srv.Shutdown(shutdownCtx)
 }
@@ -1,8 +1,13 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"testing"
 	"time"
 
@@ -74,7 +79,7 @@ func metricName(subsystem string, name string) string {
 
 func newTestingServer(cfg config.Config, st domain.Store) *httptest.Server {
 	exp := collector.New(cfg, st)
-	httpSrv := New(cfg, exp)
+	httpSrv := New(cfg, nil, exp)
 	return httptest.NewServer(httpSrv.srv.Handler)
 }
 
@@ -98,7 +103,7 @@ func TestResponseContainsMetrics(t *testing.T) {
 			}
 			defer db.Close() //nolint:errcheck
 
-			srv := newTestingServer(cfg, sqlstore.New(db))
+			srv := newTestingServer(cfg, sqlstore.New(db, false, nil))
 			defer srv.Close()
 
 			if cfg.ExportPools {
@@ -133,3 +138,272 @@ func TestResponseContainsMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestReloadEndpoint(t *testing.T) {
+	var reloadErr error
+	mux := newHTTPMux(collector.NewRegistry(), nil, nil, "/metrics", func() error { return reloadErr }, false)
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := srv.Client()
+
+	resp, err := client.Get(srv.URL + "/-/reload")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp, err = client.Post(srv.URL+"/-/reload", "", nil)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	reloadErr = errors.New("invalid config")
+	resp, err = client.Post(srv.URL+"/-/reload", "", nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	require.Contains(t, string(body), "could not reload config: invalid config")
+}
+
+func TestReloadEndpointLifecycle(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		cfg := config.Config{
+			TelemetryPath:   "/metrics",
+			StoreTimeout:    time.Millisecond * 200,
+			EnableLifecycle: enabled,
+		}
+		var loads int
+		reloader := collector.NewReloader(cfg, func() (config.Config, error) {
+			loads++
+			return cfg, nil
+		})
+
+		srv := httptest.NewServer(New(cfg, reloader).srv.Handler)
+		resp, err := srv.Client().Post(srv.URL+"/-/reload", "", nil)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		srv.Close()
+
+		if enabled {
+			require.Equal(t, 1, loads)
+		} else {
+			require.Zero(t, loads)
+		}
+	}
+}
+
+func TestReloadMetrics(t *testing.T) {
+	cfg := config.Config{
+		TelemetryPath: "/metrics",
+		StoreTimeout:  time.Millisecond * 200,
+	}
+	reloader := collector.NewReloader(cfg, func() (config.Config, error) { return cfg, nil })
+
+	srv := httptest.NewServer(New(cfg, reloader).srv.Handler)
+	defer srv.Close()
+
+	// the reloader metrics are exported also when collectors are selected
+	for _, path := range []string{cfg.TelemetryPath, cfg.TelemetryPath + "?collect[]=stats"} {
+		resp, err := srv.Client().Get(srv.URL + path)
+		require.NoError(t, err)
+
+		parser := expfmt.NewTextParser(model.UTF8Validation)
+		metrics, err := parser.TextToMetricFamilies(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Contains(t, metrics, "pgbouncer_exporter_config_last_reload_successful", path)
+		require.Contains(t, metrics, "pgbouncer_exporter_config_last_reload_success_timestamp_seconds", path)
+	}
+}
+
+func TestCollectParams(t *testing.T) {
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+
+	cfg := config.Config{
+		TelemetryPath:   "/metrics",
+		ExportPools:     true,
+		ExportDatabases: true,
+		ExportStats:     true,
+		ExportLists:     false,
+		StoreTimeout:    time.Millisecond * 200,
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	srv := newTestingServer(cfg, sqlstore.New(db, false, nil))
+	defer srv.Close()
+
+	mock.ExpectQuery("SHOW STATS").WillReturnRows(sqlmock.NewRows([]string{"database"}).AddRow("mydb"))
+	mock.ExpectQuery("SHOW POOLS").WillReturnRows(sqlmock.NewRows([]string{"database"}).AddRow("mydb"))
+
+	client := srv.Client()
+	resp, err := client.Get(srv.URL + cfg.TelemetryPath + "?collect[]=pools&collect[]=stats")
+	require.NoError(t, err)
+	defer resp.Body.Close() //nolint:errcheck
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	metrics, err := parser.TextToMetricFamilies(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	require.Contains(t, metrics, buildInfoMetric)
+	require.Contains(t, metrics, metricName(collector.SubsystemStats, "total_received"))
+	require.Contains(t, metrics, metricName(collector.SubsystemPools, "active_clients"))
+	require.NotContains(t, metrics, metricName(collector.SubsystemDatabases, "current_connections"))
+
+	for _, query := range []string{"collect[]=unknown", "collect[]=pools&collect[]=lists"} {
+		resp, err := client.Get(srv.URL + cfg.TelemetryPath + "?" + query)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+type testSnapshotResponse struct {
+	Targets []struct {
+		Labels     map[string]string `json:"labels"`
+		Collectors map[string]struct {
+			Error string           `json:"error"`
+			Rows  []map[string]any `json:"rows"`
+		} `json:"collectors"`
+	} `json:"targets"`
+}
+
+func getSnapshot(t *testing.T, srv *httptest.Server, query string) testSnapshotResponse {
+	resp, err := srv.Client().Get(srv.URL + "/api/v1/snapshot" + query)
+	require.NoError(t, err)
+	defer resp.Body.Close() //nolint:errcheck
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var snap testSnapshotResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&snap))
+	require.Len(t, snap.Targets, 1)
+	return snap
+}
+
+func TestSnapshotEndpoint(t *testing.T) {
+	cfg := config.Config{
+		TelemetryPath:  "/metrics",
+		EnableSnapshot: true,
+		ExportStats:    true,
+		ExportPools:    true,
+		StoreTimeout:   time.Millisecond * 200,
+		DefaultLabels:  map[string]string{"instance": "pg1"},
+		Filters: config.Filters{
+			Databases: config.Filter{Exclude: []*regexp.Regexp{regexp.MustCompile("^(?:secret)$")}},
+		},
+	}
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close() //nolint:errcheck
+
+	srv := newTestingServer(cfg, sqlstore.New(db, false, nil))
+	defer srv.Close()
+
+	mock.ExpectQuery("SHOW STATS").WillReturnRows(sqlmock.NewRows([]string{"database", "total_query_count"}).
+		AddRow("app", 10).
+		AddRow("other", 5).
+		AddRow("secret", 1))
+	mock.ExpectQuery("SHOW POOLS").WillReturnRows(sqlmock.NewRows([]string{"database", "user", "cl_active"}).
+		AddRow("app", "app", 3))
+
+	resp, err := srv.Client().Get(srv.URL + cfg.TelemetryPath)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	// the rows of the last scrape are served, the store is not queried again
+	snap := getSnapshot(t, srv, "?database=app")
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	target := snap.Targets[0]
+	require.Equal(t, map[string]string{"instance": "pg1"}, target.Labels)
+
+	stats := target.Collectors[config.CollectorStats]
+	require.Empty(t, stats.Error)
+	require.Len(t, stats.Rows, 1)
+	// only the columns returned by pgbouncer are included
+	require.Equal(t, map[string]any{"database": "app", "total_query_count": 10.0}, stats.Rows[0])
+
+	pools := target.Collectors[config.CollectorPools]
+	require.Empty(t, pools.Error)
+	require.Equal(t, []map[string]any{{"database": "app", "user": "app", "cl_active": 3.0}}, pools.Rows)
+
+	// the databases filtered out of the metrics are not served
+	stats = getSnapshot(t, srv, "").Targets[0].Collectors[config.CollectorStats]
+	require.Len(t, stats.Rows, 2)
+	require.Equal(t, "app", stats.Rows[0]["database"])
+	require.Equal(t, "other", stats.Rows[1]["database"])
+
+	resp, err = srv.Client().Post(srv.URL+"/api/v1/snapshot", "", nil)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestSnapshotEndpointFailedScrape(t *testing.T) {
+	cfg := config.Config{
+		TelemetryPath:  "/metrics",
+		EnableSnapshot: true,
+		ExportStats:    true,
+		ExportPools:    true,
+		StoreTimeout:   time.Millisecond * 200,
+	}
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close() //nolint:errcheck
+
+	srv := newTestingServer(cfg, sqlstore.New(db, false, nil))
+	defer srv.Close()
+
+	mock.ExpectQuery("SHOW STATS").WillReturnRows(sqlmock.NewRows([]string{"database", "total_query_count"}).AddRow("app", 10))
+	mock.ExpectQuery("SHOW POOLS").WillReturnError(errors.New("connection reset"))
+
+	resp, err := srv.Client().Get(srv.URL + cfg.TelemetryPath)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	// the error of the last scrape is reported in all sections
+	snap := getSnapshot(t, srv, "")
+	require.NoError(t, mock.ExpectationsWereMet())
+	for _, name := range []string{config.CollectorStats, config.CollectorPools} {
+		section := snap.Targets[0].Collectors[name]
+		require.Contains(t, section.Error, "connection reset", name)
+		require.Empty(t, section.Rows, name)
+	}
+}
+
+func TestSnapshotEndpointDisabled(t *testing.T) {
+	cfg := config.Config{
+		TelemetryPath: "/metrics",
+		ExportStats:   true,
+		StoreTimeout:  time.Millisecond * 200,
+	}
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close() //nolint:errcheck
+
+	srv := newTestingServer(cfg, sqlstore.New(db, false, nil))
+	defer srv.Close()
+
+	// the landing page is served instead, without a link to the snapshot
+	resp, err := srv.Client().Get(srv.URL + "/api/v1/snapshot")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "/metrics")
+	require.NotContains(t, string(body), "/api/v1/snapshot")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
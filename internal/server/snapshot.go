@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/collector"
+)
+
+// snapshotPath is the path of the endpoint serving the raw rows of the admin console.
+const snapshotPath = "/api/v1/snapshot"
+
+// snapshotResponse is the JSON document served by snapshotHandler.
+type snapshotResponse struct {
+	Timestamp time.Time             `json:"timestamp"`
+	Targets   []*collector.Snapshot `json:"targets"`
+}
+
+// snapshotHandler serves the snapshots of all targets as JSON, the rows can be filtered
+// using the database and user query parameters.
+type snapshotHandler struct {
+	exps []*collector.Exporter
+}
+
+func (h *snapshotHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "only GET requests allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := req.URL.Query()
+	filter := collector.SnapshotFilter{
+		Databases: query["database"],
+		Users:     query["user"],
+	}
+
+	resp := snapshotResponse{
+		Timestamp: time.Now(),
+		Targets:   make([]*collector.Snapshot, 0, len(h.exps)),
+	}
+	for _, exp := range h.exps {
+		resp.Targets = append(resp.Targets, exp.Snapshot(req.Context(), filter))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("could not write snapshot: %v", err)
+	}
+}
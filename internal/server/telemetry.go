@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/jbub/pgbouncer_exporter/internal/collector"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// collectParam is the name of the query parameter used to select collectors per scrape.
+const collectParam = "collect[]"
+
+// telemetryHandler serves metrics, when collectors are selected using the collect[] query
+// parameters a request scoped registry querying only the selected collectors is used.
+// The collectors which are not Exporters, like the Reloader, are registered in both registries.
+type telemetryHandler struct {
+	exps       []*collector.Exporter
+	collectors []prometheus.Collector
+	handler    http.Handler
+}
+
+func newTelemetryHandler(reg prometheus.Gatherer, exps []*collector.Exporter, collectors []prometheus.Collector) *telemetryHandler {
+	return &telemetryHandler{
+		exps:       exps,
+		collectors: collectors,
+		handler:    promhttp.HandlerFor(reg, promhttp.HandlerOpts{}),
+	}
+}
+
+func (h *telemetryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	collectors := req.URL.Query()[collectParam]
+	if len(collectors) == 0 {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+
+	exps := make([]*collector.Exporter, 0, len(h.exps))
+	for _, exp := range h.exps {
+		selected, err := exp.Select(collectors)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		exps = append(exps, selected)
+	}
+
+	reg := collector.NewRegistry(exps...)
+	reg.MustRegister(h.collectors...)
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, req)
+}
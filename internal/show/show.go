@@ -0,0 +1,275 @@
+// Package show prints the snapshots of the admin console rows for humans as tables, or as JSON or CSV.
+package show
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/collector"
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+)
+
+// Formats of the output.
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+// Escape sequences used to highlight the changed cells of a table.
+const (
+	highlightStart = "\x1b[7m"
+	highlightEnd   = "\x1b[0m"
+)
+
+// collectorOrder is the order in which the sections of the collectors are printed.
+var collectorOrder = []string{
+	config.CollectorPools,
+	config.CollectorDatabases,
+	config.CollectorStats,
+	config.CollectorTotals,
+	config.CollectorLists,
+}
+
+// Document represents the snapshots of all targets, it is printed as a single JSON line.
+type Document struct {
+	Timestamp time.Time             `json:"timestamp"`
+	Targets   []*collector.Snapshot `json:"targets"`
+}
+
+// Printer prints documents in one of the formats. When highlighting is enabled the cells of
+// a table which changed since the previously printed document are highlighted.
+type Printer struct {
+	w         io.Writer
+	errw      io.Writer
+	format    string
+	highlight bool
+
+	// prev holds the rows of the previously printed document keyed by the section and the row key.
+	prev map[string]map[string]any
+}
+
+// NewPrinter returns a new Printer writing the documents to w. The CSV format has no place
+// for the errors of the sections, they are written to errw instead.
+func NewPrinter(w, errw io.Writer, format string, highlight bool) (*Printer, error) {
+	if format != FormatTable && format != FormatJSON && format != FormatCSV {
+		return nil, fmt.Errorf("unsupported output format %q, must be one of %v, %v, %v", format, FormatTable, FormatJSON, FormatCSV)
+	}
+	return &Printer{
+		w:         w,
+		errw:      errw,
+		format:    format,
+		highlight: highlight,
+	}, nil
+}
+
+// section is a named section of a snapshot.
+type section struct {
+	name   string
+	target string
+	collector.SnapshotSection
+}
+
+// id identifies the section across documents.
+func (s section) id() string {
+	return s.target + "/" + s.name
+}
+
+func (s section) title() string {
+	if s.target == "" {
+		return s.name
+	}
+	return s.name + " (" + s.target + ")"
+}
+
+// sections returns the sections of the document, the collectors in a fixed order
+// followed by the custom queries sorted by their command.
+func sections(doc Document) []section {
+	var res []section
+	for _, snap := range doc.Targets {
+		target := formatLabels(snap.Labels)
+		for _, name := range collectorOrder {
+			if sec, ok := snap.Collectors[name]; ok {
+				res = append(res, section{name: name, target: target, SnapshotSection: sec})
+			}
+		}
+		for _, command := range slices.Sorted(maps.Keys(snap.Queries)) {
+			res = append(res, section{name: command, target: target, SnapshotSection: snap.Queries[command]})
+		}
+	}
+	return res
+}
+
+func formatLabels(labels map[string]string) string {
+	parts := make([]string, 0, len(labels))
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		parts = append(parts, name+"="+labels[name])
+	}
+	return strings.Join(parts, ",")
+}
+
+// Print prints the document.
+func (p *Printer) Print(doc Document) error {
+	switch p.format {
+	case FormatJSON:
+		return json.NewEncoder(p.w).Encode(doc)
+	case FormatCSV:
+		return p.printCSV(doc)
+	default:
+		return p.printTable(doc)
+	}
+}
+
+func (p *Printer) printCSV(doc Document) error {
+	w := csv.NewWriter(p.w)
+	first := true
+	for _, sec := range sections(doc) {
+		if sec.Error != "" {
+			_, _ = fmt.Fprintf(p.errw, "%v: %v\n", sec.title(), sec.Error)
+			continue
+		}
+		if !first {
+			// sections have different columns, each starts with its own header after an empty line
+			w.Write(nil) //nolint:errcheck
+		}
+		first = false
+
+		w.Write(append([]string{"section", "target"}, sec.Columns...)) //nolint:errcheck
+		for _, row := range sec.Rows {
+			record := []string{sec.name, sec.target}
+			for _, column := range sec.Columns {
+				record = append(record, formatValue(row[column]))
+			}
+			w.Write(record) //nolint:errcheck
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (p *Printer) printTable(doc Document) error {
+	var (
+		sb   strings.Builder
+		prev = p.prev
+	)
+	p.prev = make(map[string]map[string]any)
+
+	for i, sec := range sections(doc) {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(sec.title())
+		sb.WriteByte('\n')
+		if sec.Error != "" {
+			sb.WriteString("error: " + sec.Error + "\n")
+			continue
+		}
+
+		cells := make([][]string, 0, len(sec.Rows)+1)
+		changed := make([][]bool, 0, len(sec.Rows)+1)
+		cells = append(cells, sec.Columns)
+		changed = append(changed, make([]bool, len(sec.Columns)))
+
+		for _, row := range sec.Rows {
+			key := sec.id() + "/" + rowKey(sec.Columns, row)
+			p.prev[key] = row
+			prevRow, seen := prev[key]
+
+			rowCells := make([]string, 0, len(sec.Columns))
+			rowChanged := make([]bool, 0, len(sec.Columns))
+			for _, column := range sec.Columns {
+				rowCells = append(rowCells, formatValue(row[column]))
+				// all cells of a new row are highlighted, except in the first document
+				rowChanged = append(rowChanged, p.highlight && prev != nil && (!seen || prevRow[column] != row[column]))
+			}
+			cells = append(cells, rowCells)
+			changed = append(changed, rowChanged)
+		}
+		writeTable(&sb, cells, changed, numericColumns(sec))
+	}
+
+	_, err := io.WriteString(p.w, sb.String())
+	return err
+}
+
+// writeTable writes the cells aligned to columns separated by two spaces, the numeric columns are
+// aligned to the right. The widths are computed before highlighting so the escape sequences
+// do not break the alignment.
+func writeTable(sb *strings.Builder, cells [][]string, changed [][]bool, numeric []bool) {
+	widths := make([]int, len(numeric))
+	for _, row := range cells {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	for r, row := range cells {
+		for i, cell := range row {
+			if i > 0 {
+				sb.WriteString("  ")
+			}
+			pad := strings.Repeat(" ", widths[i]-len(cell))
+			if changed[r][i] {
+				cell = highlightStart + cell + highlightEnd
+			}
+			switch {
+			case numeric[i]:
+				sb.WriteString(pad + cell)
+			case i < len(row)-1:
+				sb.WriteString(cell + pad)
+			default:
+				sb.WriteString(cell)
+			}
+		}
+		sb.WriteByte('\n')
+	}
+}
+
+// numericColumns reports for each column of the section whether its values are numbers.
+func numericColumns(sec section) []bool {
+	res := make([]bool, len(sec.Columns))
+	if len(sec.Rows) == 0 {
+		return res
+	}
+	for i, column := range sec.Columns {
+		switch sec.Rows[0][column].(type) {
+		case int64, float64:
+			res[i] = true
+		}
+	}
+	return res
+}
+
+// rowKey identifies the row across documents by the values of its text columns,
+// like the database and user of a pool.
+func rowKey(columns []string, row map[string]any) string {
+	var parts []string
+	for _, column := range columns {
+		if value, ok := row[column].(string); ok {
+			parts = append(parts, column+"="+value)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
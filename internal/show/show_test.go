@@ -0,0 +1,116 @@
+package show
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/collector"
+	"github.com/jbub/pgbouncer_exporter/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDocument(active int64) Document {
+	return Document{
+		Timestamp: time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC),
+		Targets: []*collector.Snapshot{
+			{
+				Labels: map[string]string{"instance": "pg1"},
+				Collectors: map[string]collector.SnapshotSection{
+					config.CollectorPools: {
+						Columns: []string{"database", "user", "cl_active", "pool_mode"},
+						Rows: []map[string]any{
+							{"database": "app", "user": "app", "cl_active": active, "pool_mode": "transaction"},
+							{"database": "pgbouncer", "user": "pgbouncer", "cl_active": int64(1), "pool_mode": "statement"},
+						},
+					},
+					config.CollectorStats: {
+						Error: "connection reset",
+					},
+				},
+				Queries: map[string]collector.SnapshotSection{
+					"SHOW MEM": {
+						Columns: []string{"name", "size"},
+						Rows:    []map[string]any{{"name": "user_cache", "size": 512.0}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestPrintTable(t *testing.T) {
+	var buf bytes.Buffer
+	printer, err := NewPrinter(&buf, nil, FormatTable, false)
+	require.NoError(t, err)
+
+	require.NoError(t, printer.Print(newTestDocument(12)))
+	require.Equal(t, `pools (instance=pg1)
+database   user       cl_active  pool_mode
+app        app               12  transaction
+pgbouncer  pgbouncer          1  statement
+
+stats (instance=pg1)
+error: connection reset
+
+SHOW MEM (instance=pg1)
+name        size
+user_cache   512
+`, buf.String())
+}
+
+func TestPrintTableHighlight(t *testing.T) {
+	var buf bytes.Buffer
+	printer, err := NewPrinter(&buf, nil, FormatTable, true)
+	require.NoError(t, err)
+
+	// nothing is highlighted in the first document
+	require.NoError(t, printer.Print(newTestDocument(12)))
+	require.NotContains(t, buf.String(), highlightStart)
+
+	buf.Reset()
+	require.NoError(t, printer.Print(newTestDocument(3)))
+	require.Contains(t, buf.String(), "app        app                "+highlightStart+"3"+highlightEnd+"  transaction\n")
+	require.Contains(t, buf.String(), "pgbouncer  pgbouncer          1  statement\n")
+}
+
+func TestPrintCSV(t *testing.T) {
+	var buf, errBuf bytes.Buffer
+	printer, err := NewPrinter(&buf, &errBuf, FormatCSV, false)
+	require.NoError(t, err)
+
+	require.NoError(t, printer.Print(newTestDocument(12)))
+	require.Equal(t, `section,target,database,user,cl_active,pool_mode
+pools,instance=pg1,app,app,12,transaction
+pools,instance=pg1,pgbouncer,pgbouncer,1,statement
+
+section,target,name,size
+SHOW MEM,instance=pg1,user_cache,512
+`, buf.String())
+	require.Equal(t, "stats (instance=pg1): connection reset\n", errBuf.String())
+}
+
+func TestPrintJSON(t *testing.T) {
+	var buf bytes.Buffer
+	printer, err := NewPrinter(&buf, nil, FormatJSON, false)
+	require.NoError(t, err)
+
+	require.NoError(t, printer.Print(newTestDocument(12)))
+	require.NoError(t, printer.Print(newTestDocument(3)))
+
+	// every document is printed on a single line
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var doc Document
+	require.NoError(t, json.Unmarshal(lines[1], &doc))
+	require.Equal(t, 3.0, doc.Targets[0].Collectors[config.CollectorPools].Rows[0]["cl_active"])
+	require.Equal(t, "connection reset", doc.Targets[0].Collectors[config.CollectorStats].Error)
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	_, err := NewPrinter(nil, nil, "yaml", false)
+	require.EqualError(t, err, `unsupported output format "yaml", must be one of table, json, csv`)
+}
@@ -0,0 +1,217 @@
+package sqlstore
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// PgxStore is a pgx based Store implementation. It uses the simple query protocol, which
+// is what the pgbouncer admin console supports, over a single persistent connection
+// which is reestablished when it breaks.
+type PgxStore struct {
+	resolve func() (string, error)
+	unknown *unknownColumns
+
+	mu   sync.Mutex // guards conn and serializes queries on it
+	conn *pgx.Conn
+}
+
+// NewPgx returns a new PgxStore, resolve returns the data source name and is called on every
+// connect so that rotated credentials are picked up. No connection is established until the first query.
+// In strict mode queries returning unknown columns fail instead of ignoring the columns,
+// known columns are not treated as unknown even though the rows have no field for them.
+func NewPgx(resolve func() (string, error), strict bool, known []domain.Column) *PgxStore {
+	return &PgxStore{
+		resolve: resolve,
+		unknown: newUnknownColumns(strict, known),
+	}
+}
+
+// UnknownColumns returns the columns which are not known to the store and the number of results they were returned in.
+func (s *PgxStore) UnknownColumns() []domain.UnknownColumn {
+	return s.unknown.list()
+}
+
+// Close closes the connection.
+func (s *PgxStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close(context.Background())
+	s.conn = nil
+	return err
+}
+
+// GetStats returns stats.
+func (s *PgxStore) GetStats(ctx context.Context) ([]domain.Stat, error) {
+	var stats []domain.Stat
+	err := s.query(ctx, commandStats, func(r rows) (err error) {
+		stats, err = scanStats(r, s.unknown)
+		return err
+	})
+	return stats, err
+}
+
+// GetPools returns pools.
+func (s *PgxStore) GetPools(ctx context.Context) ([]domain.Pool, error) {
+	var pools []domain.Pool
+	err := s.query(ctx, commandPools, func(r rows) (err error) {
+		pools, err = scanPools(r, s.unknown)
+		return err
+	})
+	return pools, err
+}
+
+// GetDatabases returns databases.
+func (s *PgxStore) GetDatabases(ctx context.Context) ([]domain.Database, error) {
+	var databases []domain.Database
+	err := s.query(ctx, commandDatabases, func(r rows) (err error) {
+		databases, err = scanDatabases(r, s.unknown)
+		return err
+	})
+	return databases, err
+}
+
+// GetLists returns lists.
+func (s *PgxStore) GetLists(ctx context.Context) ([]domain.List, error) {
+	var lists []domain.List
+	err := s.query(ctx, commandLists, func(r rows) (err error) {
+		lists, err = scanLists(r, s.unknown)
+		return err
+	})
+	return lists, err
+}
+
+// GetTotals returns totals.
+func (s *PgxStore) GetTotals(ctx context.Context) (*domain.Totals, error) {
+	var totals *domain.Totals
+	err := s.query(ctx, commandTotals, func(r rows) (err error) {
+		totals, err = scanTotals(r, s.unknown)
+		return err
+	})
+	return totals, err
+}
+
+// Query runs the admin console command and returns its rows.
+func (s *PgxStore) Query(ctx context.Context, command string) ([]domain.Row, error) {
+	var result []domain.Row
+	err := s.query(ctx, command, func(r rows) (err error) {
+		result, err = scanGeneric(r)
+		return err
+	})
+	return result, err
+}
+
+// Check checks the health of the store.
+func (s *PgxStore) Check(ctx context.Context) error {
+	return s.query(ctx, commandVersion, func(r rows) error {
+		for r.Next() {
+		}
+		return r.Err()
+	})
+}
+
+func (s *PgxStore) query(ctx context.Context, sql string, scan func(rows) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	connected := s.conn != nil
+	err := s.tryQuery(ctx, sql, scan)
+	if err != nil && connected && s.conn == nil && ctx.Err() == nil {
+		// the connection broke while idle, admin console commands are read only so they are safe to retry
+		err = s.tryQuery(ctx, sql, scan)
+	}
+	return err
+}
+
+func (s *PgxStore) tryQuery(ctx context.Context, sql string, scan func(rows) error) error {
+	conn, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = queryConn(ctx, conn, sql, scan)
+	if err != nil && conn.IsClosed() {
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *PgxStore) connect(ctx context.Context) (*pgx.Conn, error) {
+	if s.conn != nil && !s.conn.IsClosed() {
+		return s.conn, nil
+	}
+
+	dsn, err := s.resolve()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+
+	conn, err := pgx.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return conn, nil
+}
+
+func queryConn(ctx context.Context, conn *pgx.Conn, sql string, scan func(rows) error) error {
+	r, err := conn.Query(ctx, sql)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := scan(pgxRows{r}); err != nil {
+		return err
+	}
+	r.Close()
+	return r.Err()
+}
+
+// pgxRows adapts pgx.Rows to the rows interface used by the column mapping.
+type pgxRows struct {
+	pgx.Rows
+}
+
+func (r pgxRows) Columns() ([]string, error) {
+	fields := r.FieldDescriptions()
+	columns := make([]string, 0, len(fields))
+	for _, field := range fields {
+		columns = append(columns, field.Name)
+	}
+	return columns, nil
+}
+
+func (r pgxRows) NumericColumns() ([]bool, error) {
+	fields := r.FieldDescriptions()
+	numeric := make([]bool, 0, len(fields))
+	for _, field := range fields {
+		numeric = append(numeric, slices.Contains(numericOIDs, field.DataTypeOID))
+	}
+	return numeric, nil
+}
+
+// numericOIDs are the type OIDs of numeric columns.
+var numericOIDs = []uint32{
+	pgtype.Int2OID,
+	pgtype.Int4OID,
+	pgtype.Int8OID,
+	pgtype.Float4OID,
+	pgtype.Float8OID,
+	pgtype.NumericOID,
+}
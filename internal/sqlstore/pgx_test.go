@@ -0,0 +1,186 @@
+package sqlstore
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+	"github.com/jbub/pgbouncer_exporter/internal/pgbouncertest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mapToResult converts the row data used by the sqlmock tests to the result of the fake admin console.
+func mapToResult(data map[string]any) pgbouncertest.Result {
+	var res pgbouncertest.Result
+	var row []*string
+
+	for _, column := range slices.Sorted(maps.Keys(data)) {
+		var value string
+		switch v := data[column].(type) {
+		case int:
+			res.Columns = append(res.Columns, pgbouncertest.Column{Name: column, Type: pgbouncertest.TypeInt8})
+			value = strconv.Itoa(v)
+		case string:
+			res.Columns = append(res.Columns, pgbouncertest.Column{Name: column, Type: pgbouncertest.TypeText})
+			value = v
+		}
+		row = append(row, &value)
+	}
+
+	res.Rows = [][]*string{row}
+	return res
+}
+
+func newFakeAdmin(t *testing.T, results map[string]pgbouncertest.Result) *pgbouncertest.Server {
+	srv := pgbouncertest.NewServer(&pgbouncertest.Fixture{Version: "1.24.1", Results: results})
+	t.Cleanup(func() { _ = srv.Close() })
+	return srv
+}
+
+func newTestPgxStore(srv *pgbouncertest.Server, strict bool) *PgxStore {
+	return NewPgx(func() (string, error) { return srv.URL(), nil }, strict, nil)
+}
+
+func dataResults() map[string]pgbouncertest.Result {
+	return map[string]pgbouncertest.Result{
+		"SHOW STATS":     mapToResult(statsData),
+		"SHOW POOLS":     mapToResult(poolsData),
+		"SHOW DATABASES": mapToResult(databasesData),
+		"SHOW LISTS":     mapToResult(listsData),
+		"SHOW VERSION":   mapToResult(map[string]any{"version": "PgBouncer 1.24.1"}),
+	}
+}
+
+func TestPgxStore(t *testing.T) {
+	srv := newFakeAdmin(t, dataResults())
+
+	st := newTestPgxStore(srv, false)
+	defer st.Close() //nolint:errcheck
+
+	ctx := context.Background()
+	require.NoError(t, st.Check(ctx))
+
+	stats, err := st.GetStats(ctx)
+	require.NoError(t, err)
+	requireStats(t, stats)
+
+	pools, err := st.GetPools(ctx)
+	require.NoError(t, err)
+	requirePools(t, pools)
+
+	databases, err := st.GetDatabases(ctx)
+	require.NoError(t, err)
+	requireDatabases(t, databases)
+
+	lists, err := st.GetLists(ctx)
+	require.NoError(t, err)
+	requireLists(t, lists)
+
+	require.Equal(t, int64(1), srv.Connects())
+}
+
+func TestPgxStoreReconnect(t *testing.T) {
+	srv := newFakeAdmin(t, dataResults())
+	srv.SetCloseAfterQuery(true)
+
+	st := newTestPgxStore(srv, false)
+	defer st.Close() //nolint:errcheck
+
+	ctx := context.Background()
+	for range 3 {
+		lists, err := st.GetLists(ctx)
+		require.NoError(t, err)
+		requireLists(t, lists)
+	}
+	require.Equal(t, int64(3), srv.Connects())
+}
+
+func TestPgxStoreUnknownNumericColumns(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+
+	srv := newFakeAdmin(t, map[string]pgbouncertest.Result{
+		"SHOW POOLS": {
+			Columns: []pgbouncertest.Column{
+				{Name: "database", Type: pgbouncertest.TypeText},
+				{Name: "user", Type: pgbouncertest.TypeText},
+				{Name: "cl_active", Type: pgbouncertest.TypeInt8},
+				{Name: "sv_new", Type: pgbouncertest.TypeInt4},
+				{Name: "sv_ratio", Type: pgbouncertest.TypeNumeric},
+				{Name: "sv_null", Type: pgbouncertest.TypeInt8},
+			},
+			Rows: [][]*string{
+				{ptr("db1"), ptr("user1"), ptr("1"), ptr("3"), ptr("0.5"), nil},
+				{ptr("db2"), ptr("user2"), ptr("2"), ptr("4"), ptr("1.5"), nil},
+			},
+		},
+	})
+
+	st := newTestPgxStore(srv, false)
+	defer st.Close() //nolint:errcheck
+
+	pools, err := st.GetPools(context.Background())
+	require.NoError(t, err)
+
+	columns := []string{"database", "user", "cl_active", "sv_new", "sv_ratio", "sv_null"}
+	require.Equal(t, []domain.Pool{
+		{Database: "db1", User: "user1", Active: 1, Extra: domain.Row{Values: domain.Values{"sv_new": 3, "sv_ratio": 0.5}, Columns: columns}},
+		{Database: "db2", User: "user2", Active: 2, Extra: domain.Row{Values: domain.Values{"sv_new": 4, "sv_ratio": 1.5}, Columns: columns}},
+	}, pools)
+}
+
+func TestPgxStoreQuery(t *testing.T) {
+	srv := newFakeAdmin(t, map[string]pgbouncertest.Result{
+		"SHOW STATS_TOTALS": mapToResult(map[string]any{"database": "main", "total_xact_count": 10}),
+	})
+
+	st := newTestPgxStore(srv, true)
+	defer st.Close() //nolint:errcheck
+
+	rows, err := st.Query(context.Background(), "SHOW STATS_TOTALS")
+	require.NoError(t, err)
+	require.Equal(t, []domain.Row{{
+		Labels:  map[string]string{"database": "main"},
+		Values:  domain.Values{"total_xact_count": 10},
+		Columns: []string{"database", "total_xact_count"},
+	}}, rows)
+}
+
+func TestPgxStoreUnknownColumn(t *testing.T) {
+	srv := newFakeAdmin(t, map[string]pgbouncertest.Result{
+		"SHOW LISTS": mapToResult(map[string]any{"list": "mylist", "items": 1, "unknown": 2, "unknown_text": "a"}),
+	})
+
+	st := newTestPgxStore(srv, false)
+	defer st.Close() //nolint:errcheck
+
+	lists, err := st.GetLists(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []domain.List{{
+		List:  "mylist",
+		Items: 1,
+		Extra: domain.Row{
+			Labels:  map[string]string{"unknown_text": "a"},
+			Values:  domain.Values{"unknown": 2},
+			Columns: []string{"items", "list", "unknown", "unknown_text"},
+		},
+	}}, lists)
+	require.Equal(t, []domain.UnknownColumn{
+		{Column: domain.Column{Command: "SHOW LISTS", Name: "unknown"}, Count: 1},
+		{Column: domain.Column{Command: "SHOW LISTS", Name: "unknown_text"}, Count: 1},
+	}, st.UnknownColumns())
+
+	strict := newTestPgxStore(srv, true)
+	defer strict.Close() //nolint:errcheck
+
+	_, err = strict.GetLists(context.Background())
+	require.EqualError(t, err, "unexpected column: unknown")
+
+	// the connection stays usable after the error
+	_, err = strict.GetLists(context.Background())
+	require.EqualError(t, err, "unexpected column: unknown")
+	require.Equal(t, int64(2), srv.Connects())
+}
@@ -0,0 +1,200 @@
+package sqlstore
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+)
+
+// Admin console commands queried by the stores.
+const (
+	commandStats     = domain.CommandStats
+	commandPools     = domain.CommandPools
+	commandDatabases = domain.CommandDatabases
+	commandLists     = domain.CommandLists
+	commandTotals    = domain.CommandTotals
+	commandVersion   = "SHOW VERSION"
+)
+
+// rows represents the result of an admin console command, it is implemented
+// by the adapters of sql.Rows and pgx rows so that both stores share the column mapping.
+type rows interface {
+	Columns() ([]string, error)
+	// NumericColumns reports for each column whether its type is numeric.
+	NumericColumns() ([]bool, error)
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+}
+
+func scanStats(rows rows, unknown *unknownColumns) ([]domain.Stat, error) {
+	return scanRows[domain.Stat](rows, commandStats, unknown)
+}
+
+func scanPools(rows rows, unknown *unknownColumns) ([]domain.Pool, error) {
+	return scanRows[domain.Pool](rows, commandPools, unknown)
+}
+
+func scanDatabases(rows rows, unknown *unknownColumns) ([]domain.Database, error) {
+	return scanRows[domain.Database](rows, commandDatabases, unknown)
+}
+
+func scanLists(rows rows, unknown *unknownColumns) ([]domain.List, error) {
+	return scanRows[domain.List](rows, commandLists, unknown)
+}
+
+// scanTotals scans the name and value rows into the fields of the totals declared by the column tags,
+// the other names are handed over to unknown as numeric columns.
+func scanTotals(rows rows, unknown *unknownColumns) (*domain.Totals, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Equal(columns, []string{"name", "value"}) {
+		return nil, fmt.Errorf("unexpected columns: %v", strings.Join(columns, ", "))
+	}
+
+	totals := new(domain.Totals)
+
+	for rows.Next() {
+		var (
+			name  string
+			value int64
+		)
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		totals.Extra.Columns = append(totals.Extra.Columns, name)
+
+		if field, ok := domain.Field(totals, name); ok {
+			*field.(*int64) = value
+			continue
+		}
+
+		// every name is returned once, so the unknown names are counted once per command result
+		if err := unknown.check(commandTotals, name, true); err != nil {
+			return nil, err
+		}
+		sink := extraColumn{row: &totals.Extra, column: name, numeric: true}
+		if err := sink.Scan(value); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+// scanRows scans the columns into the fields of T declared by the column tags,
+// the other columns are handed over to unknown.
+func scanRows[T any](rows rows, command string, unknown *unknownColumns) ([]T, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	numeric, err := rows.NumericColumns()
+	if err != nil {
+		return nil, err
+	}
+
+	// the unknown columns are checked before the rows, so they are counted also in the results without rows
+	var zero T
+	for i, column := range columns {
+		if _, ok := domain.Field(&zero, column); ok {
+			continue
+		}
+		if err := unknown.check(command, column, numeric[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []T
+
+	for rows.Next() {
+		var row T
+		domain.Extra(&row).Columns = columns
+		dest := make([]any, 0, len(columns))
+
+		for i, column := range columns {
+			field, ok := domain.Field(&row, column)
+			if !ok {
+				dest = append(dest, extraColumn{row: domain.Extra(&row), column: column, numeric: numeric[i]})
+				continue
+			}
+
+			if s, ok := field.(*string); ok {
+				field = nullString{s}
+			}
+			dest = append(dest, field)
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// scanGeneric scans all of the columns into the generic rows.
+func scanGeneric(rows rows) ([]domain.Row, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	numeric, err := rows.NumericColumns()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []domain.Row
+
+	for rows.Next() {
+		row := domain.Row{Columns: columns}
+		dest := make([]any, 0, len(columns))
+
+		for i, column := range columns {
+			dest = append(dest, extraColumn{row: &row, column: column, numeric: numeric[i]})
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// nullString is a sql.Scanner which scans NULL as an empty string.
+type nullString struct {
+	s *string
+}
+
+func (n nullString) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*n.s = ""
+	case string:
+		*n.s = src
+	case []byte:
+		*n.s = string(src)
+	default:
+		return fmt.Errorf("unsupported type %T for a text column", src)
+	}
+	return nil
+}
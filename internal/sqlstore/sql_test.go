@@ -3,22 +3,20 @@ package sqlstore
 import (
 	"context"
 	"database/sql/driver"
+	"maps"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/require"
 )
 
-func TestGetStats(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close() //nolint:errcheck
-
-	st := New(db)
-
-	data := map[string]any{
+// Rows returned by the admin console commands, shared by the tests of all stores.
+var (
+	statsData = map[string]any{
 		"database":                      "pgbouncer",
 		"total_xact_count":              1,
 		"total_query_count":             2,
@@ -38,42 +36,7 @@ func TestGetStats(t *testing.T) {
 		"avg_server_assignment_count":   16,
 	}
 
-	mock.ExpectQuery("SHOW STATS").WillReturnRows(mapToRows(data))
-
-	stats, err := st.GetStats(context.Background())
-	require.NoError(t, err)
-	require.NoError(t, mock.ExpectationsWereMet())
-
-	stat := stats[0]
-	require.Equal(t, data["database"].(string), stat.Database)
-	require.Equal(t, int64(data["total_xact_count"].(int)), stat.TotalXactCount)
-	require.Equal(t, int64(data["total_query_count"].(int)), stat.TotalQueryCount)
-	require.Equal(t, int64(data["total_received"].(int)), stat.TotalReceived)
-	require.Equal(t, int64(data["total_sent"].(int)), stat.TotalSent)
-	require.Equal(t, int64(data["total_xact_time"].(int)), stat.TotalXactTime)
-	require.Equal(t, int64(data["total_query_time"].(int)), stat.TotalQueryTime)
-	require.Equal(t, int64(data["total_wait_time"].(int)), stat.TotalWaitTime)
-	require.Equal(t, int64(data["total_server_assignment_count"].(int)), stat.TotalServerAssignmentCount)
-	require.Equal(t, int64(data["avg_xact_count"].(int)), stat.AverageXactCount)
-	require.Equal(t, int64(data["avg_query_count"].(int)), stat.AverageQueryCount)
-	require.Equal(t, int64(data["avg_recv"].(int)), stat.AverageReceived)
-	require.Equal(t, int64(data["avg_sent"].(int)), stat.AverageSent)
-	require.Equal(t, int64(data["avg_xact_time"].(int)), stat.AverageXactTime)
-	require.Equal(t, int64(data["avg_query_time"].(int)), stat.AverageQueryTime)
-	require.Equal(t, int64(data["avg_wait_time"].(int)), stat.AverageWaitTime)
-	require.Equal(t, int64(data["avg_server_assignment_count"].(int)), stat.AverageServerAssignmentCount)
-}
-
-func TestGetPools(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close() //nolint:errcheck
-
-	st := New(db)
-
-	data := map[string]any{
+	poolsData = map[string]any{
 		"database":   "pgbouncer",
 		"user":       "myuser",
 		"cl_active":  1,
@@ -88,25 +51,89 @@ func TestGetPools(t *testing.T) {
 		"pool_mode":  "transaction",
 	}
 
-	mock.ExpectQuery("SHOW POOLS").WillReturnRows(mapToRows(data))
+	databasesData = map[string]any{
+		"database":                   "pgbouncer",
+		"name":                       "myname",
+		"host":                       "localhost",
+		"port":                       23,
+		"force_user":                 "myuser",
+		"pool_size":                  4,
+		"reserve_pool_size":          5,
+		"reserve_pool":               5,
+		"pool_mode":                  "transaction",
+		"max_connections":            7,
+		"current_connections":        8,
+		"paused":                     9,
+		"disabled":                   10,
+		"server_lifetime":            11,
+		"max_client_connections":     12,
+		"current_client_connections": 13,
+	}
 
-	pools, err := st.GetPools(context.Background())
+	listsData = map[string]any{
+		"list":  "mylist",
+		"items": 6,
+	}
+
+	// totalsData are the rows of SHOW TOTALS returned by PgBouncer 1.23 and newer.
+	totalsData = [][2]any{
+		{"total_server_assignment_count", 1},
+		{"total_xact_count", 2},
+		{"total_query_count", 3},
+		{"total_client_bytes", 4},
+		{"total_server_bytes", 5},
+		{"total_xact_time", 6},
+		{"total_query_time", 7},
+		{"total_wait_time", 8},
+		{"total_client_parse_count", 9},
+		{"total_server_parse_count", 10},
+		{"total_bind_count", 11},
+		{"avg_server_assignment_count", 12},
+		{"avg_xact_count", 13},
+		{"avg_query_count", 14},
+		{"avg_client_bytes", 15},
+		{"avg_server_bytes", 16},
+		{"avg_xact_time", 17},
+		{"avg_query_time", 18},
+		{"avg_wait_time", 19},
+		{"avg_client_parse_count", 20},
+		{"avg_server_parse_count", 21},
+		{"avg_bind_count", 22},
+	}
+)
+
+func TestGetStats(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	st := New(db, false, nil)
+
+	mock.ExpectQuery("SHOW STATS").WillReturnRows(mapToRows(statsData))
+
+	stats, err := st.GetStats(context.Background())
 	require.NoError(t, err)
 	require.NoError(t, mock.ExpectationsWereMet())
+	requireStats(t, stats)
+}
 
-	pool := pools[0]
-	require.Equal(t, data["database"].(string), pool.Database)
-	require.Equal(t, data["user"].(string), pool.User)
-	require.Equal(t, int64(data["cl_active"].(int)), pool.Active)
-	require.Equal(t, int64(data["cl_waiting"].(int)), pool.Waiting)
-	require.Equal(t, int64(data["sv_active"].(int)), pool.ServerActive)
-	require.Equal(t, int64(data["sv_idle"].(int)), pool.ServerIdle)
-	require.Equal(t, int64(data["sv_used"].(int)), pool.ServerUsed)
-	require.Equal(t, int64(data["sv_tested"].(int)), pool.ServerTested)
-	require.Equal(t, int64(data["sv_login"].(int)), pool.ServerLogin)
-	require.Equal(t, int64(data["maxwait"].(int)), pool.MaxWait)
-	require.Equal(t, int64(data["maxwait_us"].(int)), pool.MaxWaitUs)
-	require.Equal(t, data["pool_mode"].(string), pool.PoolMode)
+func TestGetPools(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	st := New(db, false, nil)
+
+	mock.ExpectQuery("SHOW POOLS").WillReturnRows(mapToRows(poolsData))
+
+	pools, err := st.GetPools(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	requirePools(t, pools)
 }
 
 func TestGetDatabases(t *testing.T) {
@@ -116,46 +143,14 @@ func TestGetDatabases(t *testing.T) {
 	}
 	defer db.Close() //nolint:errcheck
 
-	st := New(db)
+	st := New(db, false, nil)
 
-	data := map[string]any{
-		"database":            "pgbouncer",
-		"name":                "myname",
-		"host":                "localhost",
-		"port":                23,
-		"force_user":          "myuser",
-		"pool_size":           4,
-		"reserve_pool_size":   5,
-		"reserve_pool":        5,
-		"pool_mode":           "transaction",
-		"max_connections":     7,
-		"current_connections": 8,
-		"paused":              9,
-		"disabled":            10,
-		"server_lifetime":     11,
-	}
-
-	mock.ExpectQuery("SHOW DATABASES").WillReturnRows(mapToRows(data))
+	mock.ExpectQuery("SHOW DATABASES").WillReturnRows(mapToRows(databasesData))
 
 	databases, err := st.GetDatabases(context.Background())
 	require.NoError(t, err)
 	require.NoError(t, mock.ExpectationsWereMet())
-
-	database := databases[0]
-	require.Equal(t, data["database"].(string), database.Database)
-	require.Equal(t, data["name"].(string), database.Name)
-	require.Equal(t, data["host"].(string), database.Host)
-	require.Equal(t, int64(data["port"].(int)), database.Port)
-	require.Equal(t, data["force_user"].(string), database.ForceUser)
-	require.Equal(t, int64(data["pool_size"].(int)), database.PoolSize)
-	require.Equal(t, int64(data["reserve_pool_size"].(int)), database.ReservePoolSize)
-	require.Equal(t, int64(data["reserve_pool"].(int)), database.ReservePoolSize)
-	require.Equal(t, data["pool_mode"].(string), database.PoolMode)
-	require.Equal(t, int64(data["max_connections"].(int)), database.MaxConnections)
-	require.Equal(t, int64(data["current_connections"].(int)), database.CurrentConnections)
-	require.Equal(t, int64(data["paused"].(int)), database.Paused)
-	require.Equal(t, int64(data["disabled"].(int)), database.Disabled)
-	require.Equal(t, int64(data["server_lifetime"].(int)), database.ServerLifetime)
+	requireDatabases(t, databases)
 }
 
 func TestGetLists(t *testing.T) {
@@ -165,32 +160,387 @@ func TestGetLists(t *testing.T) {
 	}
 	defer db.Close() //nolint:errcheck
 
-	st := New(db)
+	st := New(db, false, nil)
+
+	mock.ExpectQuery("SHOW LISTS").WillReturnRows(mapToRows(listsData))
+
+	lists, err := st.GetLists(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	requireLists(t, lists)
+}
+
+func TestGetListsUnknownColumn(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
 
 	data := map[string]any{
-		"list":  "mylist",
-		"items": 6,
+		"list":    "mylist",
+		"items":   6,
+		"unknown": 1,
 	}
 
+	columns := []string{"items", "list", "unknown"}
+
+	st := New(db, false, nil)
+
+	mock.ExpectQuery("SHOW LISTS").WillReturnRows(mapToRows(data))
 	mock.ExpectQuery("SHOW LISTS").WillReturnRows(mapToRows(data))
 
-	lists, err := st.GetLists(context.Background())
+	for range 2 {
+		lists, err := st.GetLists(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []domain.List{{List: "mylist", Items: 6, Extra: domain.Row{Values: domain.Values{"unknown": 1}, Columns: columns}}}, lists)
+	}
+	require.Equal(t, []domain.UnknownColumn{{Column: domain.Column{Command: "SHOW LISTS", Name: "unknown"}, Count: 2}}, st.UnknownColumns())
+
+	strict := New(db, true, nil)
+
+	mock.ExpectQuery("SHOW LISTS").WillReturnRows(mapToRows(data))
+
+	_, err = strict.GetLists(context.Background())
+	require.EqualError(t, err, "unexpected column: unknown")
+	require.Empty(t, strict.UnknownColumns())
+
+	known := New(db, true, []domain.Column{{Command: "SHOW LISTS", Name: "unknown"}})
+
+	mock.ExpectQuery("SHOW LISTS").WillReturnRows(mapToRows(data))
+
+	lists, err := known.GetLists(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []domain.List{{List: "mylist", Items: 6, Extra: domain.Row{Values: domain.Values{"unknown": 1}, Columns: columns}}}, lists)
+	require.Empty(t, known.UnknownColumns())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPoolsUnknownNumericColumns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	rows := mock.NewRowsWithColumnDefinition(
+		mock.NewColumn("database").OfType("TEXT", ""),
+		mock.NewColumn("user").OfType("TEXT", ""),
+		mock.NewColumn("cl_active").OfType("INT8", int64(0)),
+		mock.NewColumn("sv_new").OfType("INT8", int64(0)),
+		mock.NewColumn("sv_ratio").OfType("NUMERIC", []byte(nil)),
+		mock.NewColumn("sv_state").OfType("TEXT", ""),
+	)
+	rows.AddRow("db1", "user1", 1, 3, []byte("0.5"), "idle")
+	rows.AddRow("db2", "user2", 2, 4, nil, "busy")
+
+	st := New(db, false, nil)
+
+	mock.ExpectQuery("SHOW POOLS").WillReturnRows(rows)
+
+	pools, err := st.GetPools(context.Background())
 	require.NoError(t, err)
+
+	columns := []string{"database", "user", "cl_active", "sv_new", "sv_ratio", "sv_state"}
+	require.Equal(t, []domain.Pool{
+		{
+			Database: "db1",
+			User:     "user1",
+			Active:   1,
+			Extra: domain.Row{
+				Labels:  map[string]string{"sv_state": "idle"},
+				Values:  domain.Values{"sv_new": 3, "sv_ratio": 0.5},
+				Columns: columns,
+			},
+		},
+		{
+			Database: "db2",
+			User:     "user2",
+			Active:   2,
+			Extra: domain.Row{
+				Labels:  map[string]string{"sv_state": "busy"},
+				Values:  domain.Values{"sv_new": 4},
+				Columns: columns,
+			},
+		},
+	}, pools)
 	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPoolsUnknownColumnCount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	newRows := func(count int) *sqlmock.Rows {
+		rows := mock.NewRowsWithColumnDefinition(
+			mock.NewColumn("database").OfType("TEXT", ""),
+			mock.NewColumn("user").OfType("TEXT", ""),
+			mock.NewColumn("sv_new").OfType("INT8", int64(0)),
+		)
+		for i := range count {
+			rows.AddRow("db", "user", i)
+		}
+		return rows
+	}
+
+	st := New(db, false, nil)
+
+	// the column is counted once per command result, regardless of the number of rows
+	for _, count := range []int{5, 1, 0} {
+		mock.ExpectQuery("SHOW POOLS").WillReturnRows(newRows(count))
+
+		pools, err := st.GetPools(context.Background())
+		require.NoError(t, err)
+		require.Len(t, pools, count)
+	}
+	require.Equal(t, []domain.UnknownColumn{{Column: domain.Column{Command: "SHOW POOLS", Name: "sv_new"}, Count: 3}}, st.UnknownColumns())
+
+	strict := New(db, true, nil)
+
+	mock.ExpectQuery("SHOW POOLS").WillReturnRows(newRows(0))
+
+	_, err = strict.GetPools(context.Background())
+	require.EqualError(t, err, "unexpected column: sv_new")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func requireStats(t *testing.T, stats []domain.Stat) {
+	t.Helper()
+	require.Len(t, stats, 1)
+
+	stat := stats[0]
+	require.Equal(t, statsData["database"].(string), stat.Database)
+	require.Equal(t, int64(statsData["total_xact_count"].(int)), stat.TotalXactCount)
+	require.Equal(t, int64(statsData["total_query_count"].(int)), stat.TotalQueryCount)
+	require.Equal(t, int64(statsData["total_received"].(int)), stat.TotalReceived)
+	require.Equal(t, int64(statsData["total_sent"].(int)), stat.TotalSent)
+	require.Equal(t, int64(statsData["total_xact_time"].(int)), stat.TotalXactTime)
+	require.Equal(t, int64(statsData["total_query_time"].(int)), stat.TotalQueryTime)
+	require.Equal(t, int64(statsData["total_wait_time"].(int)), stat.TotalWaitTime)
+	require.Equal(t, int64(statsData["total_server_assignment_count"].(int)), stat.TotalServerAssignmentCount)
+	require.Equal(t, int64(statsData["avg_xact_count"].(int)), stat.AverageXactCount)
+	require.Equal(t, int64(statsData["avg_query_count"].(int)), stat.AverageQueryCount)
+	require.Equal(t, int64(statsData["avg_recv"].(int)), stat.AverageReceived)
+	require.Equal(t, int64(statsData["avg_sent"].(int)), stat.AverageSent)
+	require.Equal(t, int64(statsData["avg_xact_time"].(int)), stat.AverageXactTime)
+	require.Equal(t, int64(statsData["avg_query_time"].(int)), stat.AverageQueryTime)
+	require.Equal(t, int64(statsData["avg_wait_time"].(int)), stat.AverageWaitTime)
+	require.Equal(t, int64(statsData["avg_server_assignment_count"].(int)), stat.AverageServerAssignmentCount)
+}
+
+func requirePools(t *testing.T, pools []domain.Pool) {
+	t.Helper()
+	require.Len(t, pools, 1)
+
+	pool := pools[0]
+	require.Equal(t, poolsData["database"].(string), pool.Database)
+	require.Equal(t, poolsData["user"].(string), pool.User)
+	require.Equal(t, int64(poolsData["cl_active"].(int)), pool.Active)
+	require.Equal(t, int64(poolsData["cl_waiting"].(int)), pool.Waiting)
+	require.Equal(t, int64(poolsData["sv_active"].(int)), pool.ServerActive)
+	require.Equal(t, int64(poolsData["sv_idle"].(int)), pool.ServerIdle)
+	require.Equal(t, int64(poolsData["sv_used"].(int)), pool.ServerUsed)
+	require.Equal(t, int64(poolsData["sv_tested"].(int)), pool.ServerTested)
+	require.Equal(t, int64(poolsData["sv_login"].(int)), pool.ServerLogin)
+	require.Equal(t, int64(poolsData["maxwait"].(int)), pool.MaxWait)
+	require.Equal(t, int64(poolsData["maxwait_us"].(int)), pool.MaxWaitUs)
+	require.Equal(t, poolsData["pool_mode"].(string), pool.PoolMode)
+}
+
+func requireDatabases(t *testing.T, databases []domain.Database) {
+	t.Helper()
+	require.Len(t, databases, 1)
+
+	database := databases[0]
+	require.Equal(t, databasesData["database"].(string), database.Database)
+	require.Equal(t, databasesData["name"].(string), database.Name)
+	require.Equal(t, databasesData["host"].(string), database.Host)
+	require.Equal(t, int64(databasesData["port"].(int)), database.Port)
+	require.Equal(t, databasesData["force_user"].(string), database.ForceUser)
+	require.Equal(t, int64(databasesData["pool_size"].(int)), database.PoolSize)
+	require.Equal(t, int64(databasesData["reserve_pool_size"].(int)), database.ReservePoolSize)
+	require.Equal(t, int64(databasesData["reserve_pool"].(int)), database.ReservePoolSize)
+	require.Equal(t, databasesData["pool_mode"].(string), database.PoolMode)
+	require.Equal(t, int64(databasesData["max_connections"].(int)), database.MaxConnections)
+	require.Equal(t, int64(databasesData["current_connections"].(int)), database.CurrentConnections)
+	require.Equal(t, int64(databasesData["paused"].(int)), database.Paused)
+	require.Equal(t, int64(databasesData["disabled"].(int)), database.Disabled)
+	require.Equal(t, int64(databasesData["server_lifetime"].(int)), database.ServerLifetime)
+	require.Equal(t, int64(databasesData["max_client_connections"].(int)), database.MaxClientConnections)
+	require.Equal(t, int64(databasesData["current_client_connections"].(int)), database.CurrentClientConnections)
+}
+
+func requireLists(t *testing.T, lists []domain.List) {
+	t.Helper()
+	require.Len(t, lists, 1)
 
 	list := lists[0]
-	require.Equal(t, data["list"].(string), list.List)
-	require.Equal(t, int64(data["items"].(int)), list.Items)
+	require.Equal(t, listsData["list"].(string), list.List)
+	require.Equal(t, int64(listsData["items"].(int)), list.Items)
 }
 
+func TestGetTotals(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	st := New(db, true, nil)
+
+	mock.ExpectQuery("SHOW TOTALS").WillReturnRows(totalsRows(totalsData))
+
+	totals, err := st.GetTotals(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	names := make([]string, 0, len(totalsData))
+	for _, row := range totalsData {
+		names = append(names, row[0].(string))
+	}
+	require.Equal(t, &domain.Totals{
+		TotalServerAssignmentCount:   1,
+		TotalXactCount:               2,
+		TotalQueryCount:              3,
+		TotalReceived:                4,
+		TotalSent:                    5,
+		TotalXactTime:                6,
+		TotalQueryTime:               7,
+		TotalWaitTime:                8,
+		TotalClientParseCount:        9,
+		TotalServerParseCount:        10,
+		TotalBindCount:               11,
+		AverageServerAssignmentCount: 12,
+		AverageXactCount:             13,
+		AverageQueryCount:            14,
+		AverageReceived:              15,
+		AverageSent:                  16,
+		AverageXactTime:              17,
+		AverageQueryTime:             18,
+		AverageWaitTime:              19,
+		AverageClientParseCount:      20,
+		AverageServerParseCount:      21,
+		AverageBindCount:             22,
+		Extra:                        domain.Row{Columns: names},
+	}, totals)
+}
+
+func TestGetTotalsUnknownName(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	data := [][2]any{{"total_xact_count", 2}, {"total_new_count", 3}}
+
+	st := New(db, false, nil)
+
+	mock.ExpectQuery("SHOW TOTALS").WillReturnRows(totalsRows(data))
+
+	totals, err := st.GetTotals(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &domain.Totals{TotalXactCount: 2, Extra: domain.Row{
+		Values:  domain.Values{"total_new_count": 3},
+		Columns: []string{"total_xact_count", "total_new_count"},
+	}}, totals)
+	require.Equal(t, []domain.UnknownColumn{{Column: domain.Column{Command: "SHOW TOTALS", Name: "total_new_count"}, Count: 1}}, st.UnknownColumns())
+
+	strict := New(db, true, nil)
+
+	mock.ExpectQuery("SHOW TOTALS").WillReturnRows(totalsRows(data))
+
+	_, err = strict.GetTotals(context.Background())
+	require.EqualError(t, err, "unexpected column: total_new_count")
+
+	mock.ExpectQuery("SHOW TOTALS").WillReturnRows(mapToRows(listsData))
+
+	_, err = st.GetTotals(context.Background())
+	require.ErrorContains(t, err, "unexpected columns")
+}
+
+func TestQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	st := New(db, true, nil)
+
+	mock.ExpectQuery("SHOW STATS_TOTALS").WillReturnRows(mapToRows(map[string]any{"database": "main", "total_xact_count": 10}))
+
+	rows, err := st.Query(context.Background(), "SHOW STATS_TOTALS")
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, []domain.Row{{
+		Labels:  map[string]string{"database": "main"},
+		Values:  domain.Values{"total_xact_count": 10},
+		Columns: []string{"database", "total_xact_count"},
+	}}, rows)
+	require.Empty(t, st.UnknownColumns())
+}
+
+func totalsRows(data [][2]any) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"name", "value"})
+	for _, row := range data {
+		rows.AddRow(row[0], row[1])
+	}
+	return rows
+}
+
+// mapToRows returns a row of the values in data, the columns are sorted by name.
 func mapToRows(data map[string]any) *sqlmock.Rows {
-	columns := make([]string, 0, len(data))
+	columns := slices.Sorted(maps.Keys(data))
 	values := make([]driver.Value, 0, len(data))
-	for k, v := range data {
-		columns = append(columns, k)
-		values = append(values, v)
+	for _, column := range columns {
+		values = append(values, data[column])
 	}
 	rows := sqlmock.NewRows(columns)
 	rows.AddRow(values...)
 	return rows
 }
+
+func TestSwapWaitsForQueries(t *testing.T) {
+	prev, prevMock, err := sqlmock.New()
+	require.NoError(t, err)
+	next, nextMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer next.Close() //nolint:errcheck
+
+	st := New(prev, false, nil)
+
+	prevMock.ExpectQuery("SHOW LISTS").WillReturnRows(mapToRows(listsData))
+	prevMock.ExpectClose()
+	nextMock.ExpectQuery("SHOW LISTS").WillReturnRows(mapToRows(listsData))
+
+	// a query is running on the previous handle while it is swapped
+	db, release := st.acquire()
+	rows, err := db.QueryContext(context.Background(), "SHOW LISTS")
+	require.NoError(t, err)
+
+	swapped := make(chan error)
+	go func() { swapped <- st.Swap(next) }()
+
+	// new queries use the new handle right away
+	require.Eventually(t, func() bool { return st.conn() == next }, time.Second, time.Millisecond)
+	_, err = st.GetLists(context.Background())
+	require.NoError(t, err)
+
+	select {
+	case err := <-swapped:
+		t.Fatalf("previous handle closed while in use: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Close())
+	release()
+
+	require.NoError(t, <-swapped)
+	require.NoError(t, prevMock.ExpectationsWereMet())
+	require.NoError(t, nextMock.ExpectationsWereMet())
+}
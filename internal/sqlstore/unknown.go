@@ -0,0 +1,124 @@
+package sqlstore
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/jbub/pgbouncer_exporter/internal/domain"
+)
+
+// unknownColumns handles the columns which have no field in the typed rows, they are scanned
+// into the extra columns of the row. Columns which are not in the known set, for example
+// used by metric mappings, fail the query in strict mode, otherwise they are counted.
+type unknownColumns struct {
+	strict bool
+	known  map[domain.Column]struct{}
+
+	mu   sync.Mutex // guards seen
+	seen map[domain.Column]int64
+}
+
+func newUnknownColumns(strict bool, known []domain.Column) *unknownColumns {
+	u := &unknownColumns{
+		strict: strict,
+		known:  make(map[domain.Column]struct{}, len(known)),
+		seen:   make(map[domain.Column]int64),
+	}
+	for _, col := range known {
+		u.known[col] = struct{}{}
+	}
+	return u
+}
+
+// check checks the column which has no field in the typed rows, it is called once per command result
+// so the column is counted once regardless of the number of rows.
+func (u *unknownColumns) check(command string, column string, numeric bool) error {
+	col := domain.Column{Command: command, Name: column}
+
+	if _, ok := u.known[col]; ok {
+		return nil
+	}
+	if u.strict {
+		return fmt.Errorf("unexpected column: %v", column)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.seen[col]++
+	if u.seen[col] == 1 {
+		if numeric {
+			log.Printf("Exporting unknown numeric column %v returned by %v", column, command)
+		} else {
+			log.Printf("Ignoring unknown column %v returned by %v", column, command)
+		}
+	}
+	return nil
+}
+
+func (u *unknownColumns) list() []domain.UnknownColumn {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	columns := slices.SortedFunc(maps.Keys(u.seen), func(a, b domain.Column) int {
+		return cmp.Or(cmp.Compare(a.Command, b.Command), cmp.Compare(a.Name, b.Name))
+	})
+	res := make([]domain.UnknownColumn, 0, len(columns))
+	for _, col := range columns {
+		res = append(res, domain.UnknownColumn{Column: col, Count: u.seen[col]})
+	}
+	return res
+}
+
+// extraColumn is a sql.Scanner which stores the value of a column into the extra columns
+// of the row, numeric values into the values and the others into the labels. NULL values are skipped.
+type extraColumn struct {
+	row     *domain.Row
+	column  string
+	numeric bool
+}
+
+func (c extraColumn) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case int64:
+		c.setValue(float64(src))
+	case float64:
+		c.setValue(src)
+	case []byte:
+		return c.Scan(string(src))
+	case string:
+		if !c.numeric {
+			c.setLabel(src)
+			return nil
+		}
+		value, err := strconv.ParseFloat(src, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse column %v: %v", c.column, err)
+		}
+		c.setValue(value)
+	default:
+		return fmt.Errorf("unsupported type %T of column %v", src, c.column)
+	}
+	return nil
+}
+
+func (c extraColumn) setValue(value float64) {
+	if c.row.Values == nil {
+		c.row.Values = make(domain.Values)
+	}
+	c.row.Values[c.column] = value
+}
+
+func (c extraColumn) setLabel(label string) {
+	if c.row.Labels == nil {
+		c.row.Labels = make(map[string]string)
+	}
+	c.row.Labels[c.column] = label
+}
@@ -0,0 +1,65 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Watcher periodically resolves the data source name and reconnects the Store when it changes.
+type Watcher struct {
+	store    *Store
+	resolve  func() (string, error)
+	interval time.Duration
+	dsn      string
+}
+
+// NewWatcher returns a new Watcher, dsn is the data source name the store is currently connected with.
+func NewWatcher(store *Store, dsn string, resolve func() (string, error), interval time.Duration) *Watcher {
+	return &Watcher{
+		store:    store,
+		resolve:  resolve,
+		interval: interval,
+		dsn:      dsn,
+	}
+}
+
+// Run runs the watcher until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.check(); err != nil {
+				log.Printf("could not reload database credentials: %v", err)
+			}
+		}
+	}
+}
+
+func (w *Watcher) check() error {
+	dsn, err := w.resolve()
+	if err != nil {
+		return err
+	}
+	if dsn == w.dsn {
+		return nil
+	}
+
+	db, err := Open(dsn)
+	if err != nil {
+		return fmt.Errorf("could not open db: %v", err)
+	}
+	if err := w.store.Swap(db); err != nil {
+		log.Printf("could not close previous db: %v", err)
+	}
+	w.dsn = dsn
+
+	log.Println("Database credentials changed, reconnected store")
+	return nil
+}
@@ -0,0 +1,39 @@
+package sqlstore
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcherCheck(t *testing.T) {
+	const initial = "host=/var/run/pgbouncer dbname=pgbouncer password=old"
+
+	db, err := Open(initial)
+	require.NoError(t, err)
+
+	st := New(db, false, nil)
+	defer st.conn().Close() //nolint:errcheck
+
+	dsn := initial
+	var resolveErr error
+	w := NewWatcher(st, initial, func() (string, error) { return dsn, resolveErr }, 0)
+
+	require.NoError(t, w.check())
+	require.Same(t, db, st.conn())
+
+	dsn = "host=/var/run/pgbouncer dbname=pgbouncer password=new"
+	require.NoError(t, w.check())
+	require.NotSame(t, db, st.conn())
+
+	swapped := st.conn()
+	resolveErr = errors.New("file not found")
+	require.Error(t, w.check())
+	require.Same(t, swapped, st.conn())
+
+	resolveErr = nil
+	dsn = "sslmode=invalid"
+	require.Error(t, w.check())
+	require.Same(t, swapped, st.conn())
+}
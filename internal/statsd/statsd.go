@@ -0,0 +1,274 @@
+// Package statsd periodically sends the metrics gathered from a prometheus registry over UDP
+// in the StatsD or DogStatsD format.
+package statsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Formats of the sent lines.
+const (
+	// FormatStatsD appends the labels to the metric name, for example name.database.app:1|g.
+	FormatStatsD = "statsd"
+	// FormatDogStatsD sends the labels as tags, for example name:1|g|#database:app.
+	FormatDogStatsD = "dogstatsd"
+)
+
+// Options represents the configuration of the Sender.
+type Options struct {
+	// Address is the host:port of the StatsD server.
+	Address string
+	// Format is one of FormatStatsD or FormatDogStatsD.
+	Format string
+	// Interval between the sends.
+	Interval time.Duration
+	// MaxPacketSize is the maximum size of a single UDP packet, lines are never split.
+	MaxPacketSize int
+}
+
+// Validate validates the options.
+func (o Options) Validate() error {
+	if o.Address == "" {
+		return errors.New("statsd address must not be empty")
+	}
+	if o.Format != FormatStatsD && o.Format != FormatDogStatsD {
+		return fmt.Errorf("unsupported statsd format %q, must be one of %v, %v", o.Format, FormatStatsD, FormatDogStatsD)
+	}
+	if o.Interval <= 0 {
+		return errors.New("statsd interval must be positive")
+	}
+	if o.MaxPacketSize <= 0 {
+		return errors.New("statsd max packet size must be positive")
+	}
+	return nil
+}
+
+// Sender sends the metrics gathered by a prometheus.Gatherer. Gauges are sent as gauges, counters
+// are sent as counters of the increase since the previous send. Gauges with _total in their name,
+// like the cumulative stats mapped as gauges, are sent as counters too. Summaries and histograms
+// are sent as the counters of their sum and count.
+type Sender struct {
+	opts     Options
+	gatherer prometheus.Gatherer
+	conn     net.Conn
+
+	// sends is the number of sends, it is used to expire the counters missing from the gathers.
+	sends int
+	// counters keeps the last gathered values of the counters.
+	counters map[string]sentCounter
+}
+
+// sentCounter represents the last gathered value of a counter and the send it was gathered by.
+type sentCounter struct {
+	value float64
+	send  int
+}
+
+// maxMissedSends is the number of sends a counter can be missing from, for example because
+// the scrape of pgbouncer failed, before its value is forgotten. Until then the increase
+// is computed from the value gathered before the counter went missing.
+const maxMissedSends = 10
+
+// New returns a new Sender of the metrics gathered by gatherer, the caller should call Close when finished.
+func New(opts Options, gatherer prometheus.Gatherer) (*Sender, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("udp", opts.Address)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to statsd: %v", err)
+	}
+
+	return &Sender{
+		opts:     opts,
+		gatherer: gatherer,
+		conn:     conn,
+		counters: make(map[string]sentCounter),
+	}, nil
+}
+
+// Run sends the metrics every interval until ctx is done, failed sends are logged.
+func (s *Sender) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Send(); err != nil {
+			log.Printf("could not send metrics: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Send gathers and sends the metrics.
+func (s *Sender) Send() error {
+	families, err := s.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("could not gather metrics: %v", err)
+	}
+
+	var packet []byte
+	for _, line := range s.lines(families) {
+		if len(packet) > 0 && len(packet)+1+len(line) > s.opts.MaxPacketSize {
+			if err := s.write(packet); err != nil {
+				return err
+			}
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		return s.write(packet)
+	}
+	return nil
+}
+
+func (s *Sender) write(packet []byte) error {
+	if _, err := s.conn.Write(packet); err != nil {
+		return fmt.Errorf("could not write packet: %v", err)
+	}
+	return nil
+}
+
+// Close closes the connection.
+func (s *Sender) Close() error {
+	return s.conn.Close()
+}
+
+// lines converts the metric families to lines and stores the values of the counters, counters
+// seen for the first time are only stored.
+func (s *Sender) lines(families []*dto.MetricFamily) []string {
+	var lines []string
+	s.sends++
+
+	gauge := func(name string, labels []*dto.LabelPair, value float64) {
+		lines = append(lines, s.line(name, labels, value, "g"))
+	}
+	counter := func(name string, labels []*dto.LabelPair, value float64) {
+		key := seriesKey(name, labels)
+		prev, ok := s.counters[key]
+		s.counters[key] = sentCounter{value: value, send: s.sends}
+		if !ok {
+			return
+		}
+		delta := value - prev.value
+		if delta < 0 {
+			// the counter was reset, all of its current value is the increase
+			delta = value
+		}
+		lines = append(lines, s.line(name, labels, delta, "c"))
+	}
+
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE:
+				if cumulative(name) {
+					counter(name, m.GetLabel(), m.GetGauge().GetValue())
+				} else {
+					gauge(name, m.GetLabel(), m.GetGauge().GetValue())
+				}
+			case dto.MetricType_UNTYPED:
+				if cumulative(name) {
+					counter(name, m.GetLabel(), m.GetUntyped().GetValue())
+				} else {
+					gauge(name, m.GetLabel(), m.GetUntyped().GetValue())
+				}
+			case dto.MetricType_COUNTER:
+				counter(name, m.GetLabel(), m.GetCounter().GetValue())
+			case dto.MetricType_SUMMARY:
+				counter(name+"_sum", m.GetLabel(), m.GetSummary().GetSampleSum())
+				counter(name+"_count", m.GetLabel(), float64(m.GetSummary().GetSampleCount()))
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				counter(name+"_sum", m.GetLabel(), m.GetHistogram().GetSampleSum())
+				counter(name+"_count", m.GetLabel(), float64(m.GetHistogram().GetSampleCount()))
+			}
+		}
+	}
+
+	// counters missing from the gathers for too long disappeared and are forgotten
+	for key, c := range s.counters {
+		if s.sends-c.send > maxMissedSends {
+			delete(s.counters, key)
+		}
+	}
+	return lines
+}
+
+// cumulative reports whether the gauge holds a cumulative value, like the stats_total_* columns
+// which are mapped as gauges, such gauges only grow until pgbouncer restarts.
+func cumulative(name string) bool {
+	return strings.HasSuffix(name, "_total") || strings.Contains(name, "_total_")
+}
+
+func (s *Sender) line(name string, labels []*dto.LabelPair, value float64, typ string) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	if s.opts.Format == FormatStatsD {
+		for _, l := range labels {
+			sb.WriteByte('.')
+			sb.WriteString(l.GetName())
+			sb.WriteByte('.')
+			sb.WriteString(escape(l.GetValue(), ".:|@#,\n"))
+		}
+	}
+	sb.WriteByte(':')
+	sb.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	sb.WriteByte('|')
+	sb.WriteString(typ)
+	if s.opts.Format == FormatDogStatsD && len(labels) > 0 {
+		sb.WriteString("|#")
+		for i, l := range labels {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(l.GetName())
+			sb.WriteByte(':')
+			sb.WriteString(escape(l.GetValue(), "|@#,\n"))
+		}
+	}
+	return sb.String()
+}
+
+// escape replaces the characters which have special meaning in the line format with underscores.
+func escape(s string, chars string) string {
+	if !strings.ContainsAny(s, chars) {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(chars, r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// seriesKey returns the key identifying a series, the labels of a gathered metric are sorted by name.
+func seriesKey(name string, labels []*dto.LabelPair) string {
+	parts := make([]string, 0, len(labels)+1)
+	parts = append(parts, name)
+	for _, l := range labels {
+		parts = append(parts, l.GetName()+"="+strconv.Quote(l.GetValue()))
+	}
+	return strings.Join(parts, ",")
+}
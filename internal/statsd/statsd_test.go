@@ -0,0 +1,263 @@
+package statsd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbub/pgbouncer_exporter/internal/metricstest"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestListener(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return conn
+}
+
+// readPackets reads the packets sent to conn until no more arrive.
+func readPackets(t *testing.T, conn net.PacketConn) []string {
+	var packets []string
+	buf := make([]byte, 65536)
+	for {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			require.ErrorAs(t, err, &netErr)
+			require.True(t, netErr.Timeout())
+			return packets
+		}
+		packets = append(packets, string(buf[:n]))
+	}
+}
+
+func newTestSender(t *testing.T, conn net.PacketConn, format string, gatherer prometheus.Gatherer) *Sender {
+	sender, err := New(Options{
+		Address:       conn.LocalAddr().String(),
+		Format:        format,
+		Interval:      time.Second,
+		MaxPacketSize: 1432,
+	}, gatherer)
+	require.NoError(t, err)
+	return sender
+}
+
+var (
+	formatCases = []struct {
+		format string
+		first  []string
+		second []string
+	}{
+		{
+			format: FormatDogStatsD,
+			first: []string{
+				"pgbouncer_exporter_pools_active_clients:12|g|#database:app.main,instance:pg1",
+			},
+			second: []string{
+				"pgbouncer_exporter_pools_active_clients:3|g|#database:app.main,instance:pg1\n" +
+					"pgbouncer_exporter_totals_queries_total:7|c|#instance:pg1",
+			},
+		},
+		{
+			format: FormatStatsD,
+			first: []string{
+				"pgbouncer_exporter_pools_active_clients.database.app_main.instance.pg1:12|g",
+			},
+			second: []string{
+				"pgbouncer_exporter_pools_active_clients.database.app_main.instance.pg1:3|g\n" +
+					"pgbouncer_exporter_totals_queries_total.instance.pg1:7|c",
+			},
+		},
+	}
+)
+
+func TestSend(t *testing.T) {
+	for _, cs := range formatCases {
+		t.Run(cs.format, func(t *testing.T) {
+			conn := newTestListener(t)
+			defer conn.Close() //nolint:errcheck
+
+			metrics := metricstest.NewMetrics("app.main")
+			sender := newTestSender(t, conn, cs.format, metrics.Registry)
+			defer sender.Close() //nolint:errcheck
+
+			// counters are only sent once their increase is known
+			require.NoError(t, sender.Send())
+			require.Equal(t, cs.first, readPackets(t, conn))
+
+			metrics.ActiveClients.WithLabelValues("app.main").Set(3)
+			metrics.Queries.Add(7)
+
+			require.NoError(t, sender.Send())
+			require.Equal(t, cs.second, readPackets(t, conn))
+		})
+	}
+}
+
+func TestSendCounterReset(t *testing.T) {
+	conn := newTestListener(t)
+	defer conn.Close() //nolint:errcheck
+
+	metrics := metricstest.NewMetrics("app.main")
+	sender := newTestSender(t, conn, FormatDogStatsD, metrics.Registry)
+	defer sender.Close() //nolint:errcheck
+
+	require.NoError(t, sender.Send())
+	readPackets(t, conn)
+
+	// the registered counter is replaced by a new one with a lower value
+	metrics.Registry.Unregister(metrics.Queries)
+	counter := metricstest.NewQueriesCounter()
+	counter.Add(40)
+	metrics.Registry.MustRegister(counter)
+
+	require.NoError(t, sender.Send())
+	packets := readPackets(t, conn)
+	require.Len(t, packets, 1)
+	require.Contains(t, strings.Split(packets[0], "\n"), "pgbouncer_exporter_totals_queries_total:40|c|#instance:pg1")
+}
+
+func TestSendFailedScrape(t *testing.T) {
+	conn := newTestListener(t)
+	defer conn.Close() //nolint:errcheck
+
+	metrics := metricstest.NewMetrics("app.main")
+	sender := newTestSender(t, conn, FormatDogStatsD, metrics.Registry)
+	defer sender.Close() //nolint:errcheck
+
+	require.NoError(t, sender.Send())
+	readPackets(t, conn)
+
+	// the counter is missing from the gather while the scrape of pgbouncer fails
+	metrics.Registry.Unregister(metrics.Queries)
+	require.NoError(t, sender.Send())
+	require.Equal(t, []string{
+		"pgbouncer_exporter_pools_active_clients:12|g|#database:app.main,instance:pg1",
+	}, readPackets(t, conn))
+
+	// the increase over the failed scrape is sent once the counter is back
+	metrics.Queries.Add(5)
+	metrics.Registry.MustRegister(metrics.Queries)
+	require.NoError(t, sender.Send())
+	packets := readPackets(t, conn)
+	require.Len(t, packets, 1)
+	require.Contains(t, strings.Split(packets[0], "\n"), "pgbouncer_exporter_totals_queries_total:5|c|#instance:pg1")
+}
+
+func TestSendExpiredCounter(t *testing.T) {
+	conn := newTestListener(t)
+	defer conn.Close() //nolint:errcheck
+
+	metrics := metricstest.NewMetrics("app.main")
+	sender := newTestSender(t, conn, FormatDogStatsD, metrics.Registry)
+	defer sender.Close() //nolint:errcheck
+
+	require.NoError(t, sender.Send())
+
+	// the counter missing for too long is forgotten and only stored when it is back
+	metrics.Registry.Unregister(metrics.Queries)
+	for range maxMissedSends + 1 {
+		require.NoError(t, sender.Send())
+	}
+	readPackets(t, conn)
+
+	metrics.Queries.Add(5)
+	metrics.Registry.MustRegister(metrics.Queries)
+	require.NoError(t, sender.Send())
+	require.Equal(t, []string{
+		"pgbouncer_exporter_pools_active_clients:12|g|#database:app.main,instance:pg1",
+	}, readPackets(t, conn))
+}
+
+func TestSendCumulativeGauge(t *testing.T) {
+	conn := newTestListener(t)
+	defer conn.Close() //nolint:errcheck
+
+	metrics := metricstest.NewMetrics("app.main")
+	queries := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        "pgbouncer_exporter_stats_total_query_count",
+		Help:        "Total number of SQL queries pooled by pgbouncer.",
+		ConstLabels: prometheus.Labels{"instance": "pg1"},
+	}, []string{"database"})
+	queries.WithLabelValues("app.main").Set(250)
+	metrics.Registry.MustRegister(queries)
+
+	sender := newTestSender(t, conn, FormatDogStatsD, metrics.Registry)
+	defer sender.Close() //nolint:errcheck
+
+	require.NoError(t, sender.Send())
+	readPackets(t, conn)
+
+	// the gauge holding a cumulative stat is sent as the counter of its increase
+	queries.WithLabelValues("app.main").Set(260)
+	require.NoError(t, sender.Send())
+	packets := readPackets(t, conn)
+	require.Len(t, packets, 1)
+	require.Contains(t, strings.Split(packets[0], "\n"), "pgbouncer_exporter_stats_total_query_count:10|c|#database:app.main,instance:pg1")
+}
+
+func TestSendMaxPacketSize(t *testing.T) {
+	conn := newTestListener(t)
+	defer conn.Close() //nolint:errcheck
+
+	metrics := metricstest.NewMetrics("app.main")
+	metrics.ActiveClients.WithLabelValues("other").Set(1)
+
+	sender, err := New(Options{
+		Address:       conn.LocalAddr().String(),
+		Format:        FormatDogStatsD,
+		Interval:      time.Second,
+		MaxPacketSize: 100,
+	}, metrics.Registry)
+	require.NoError(t, err)
+	defer sender.Close() //nolint:errcheck
+
+	require.NoError(t, sender.Send())
+	require.Equal(t, []string{
+		"pgbouncer_exporter_pools_active_clients:12|g|#database:app.main,instance:pg1",
+		"pgbouncer_exporter_pools_active_clients:1|g|#database:other,instance:pg1",
+	}, readPackets(t, conn))
+}
+
+var (
+	optionsCases = []struct {
+		name   string
+		modify func(o *Options)
+		err    string
+	}{
+		{
+			name:   "missing address",
+			modify: func(o *Options) { o.Address = "" },
+			err:    "statsd address must not be empty",
+		},
+		{
+			name:   "unsupported format",
+			modify: func(o *Options) { o.Format = "influx" },
+			err:    `unsupported statsd format "influx", must be one of statsd, dogstatsd`,
+		},
+		{
+			name:   "zero interval",
+			modify: func(o *Options) { o.Interval = 0 },
+			err:    "statsd interval must be positive",
+		},
+	}
+)
+
+func TestOptionsValidate(t *testing.T) {
+	for _, cs := range optionsCases {
+		t.Run(cs.name, func(t *testing.T) {
+			opts := Options{
+				Address:       "127.0.0.1:8125",
+				Format:        FormatStatsD,
+				Interval:      time.Second,
+				MaxPacketSize: 1432,
+			}
+			cs.modify(&opts)
+			require.EqualError(t, opts.Validate(), cs.err)
+		})
+	}
+}